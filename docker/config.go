@@ -144,8 +144,9 @@ type Data struct {
 
 // ProviderConfig for the custom registry provider
 type ProviderConfig struct {
-	DockerClient *client.Client
-	AuthConfigs  *AuthConfigs
+	DockerClient   *client.Client
+	AuthConfigs    *AuthConfigs
+	RegistryClient *registryClient
 }
 
 // The registry address can be referenced in various places (registry auth, docker config file, image name)
@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// imageArchiveManifestFile is the name of the manifest which
+// lists the images of a 'docker save' archive
+const imageArchiveManifestFile = "manifest.json"
+
+func dataSourceDockerImageArchive() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceDockerImageArchiveRead,
+
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"sha256": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"image_ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"tags": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func dataSourceDockerImageArchiveRead(d *schema.ResourceData, meta interface{}) error {
+	path := d.Get("path").(string)
+	hash, err := hashFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+
+	archive, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+	defer archive.Close()
+
+	imageIDs, tags, err := readImageArchiveManifest(archive)
+	if err != nil {
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+
+	d.SetId(hash)
+	d.Set("sha256", hash)
+	d.Set("image_ids", imageIDs)
+	d.Set("tags", tags)
+	return nil
+}
+
+// readImageArchiveManifest returns the image IDs and the tags listed
+// in the manifest of a 'docker save' archive without loading it
+func readImageArchiveManifest(archive io.Reader) ([]string, []string, error) {
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("%s not found", imageArchiveManifestFile)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if path.Clean(header.Name) != imageArchiveManifestFile {
+			continue
+		}
+
+		var manifest []struct {
+			Config   string
+			RepoTags []string
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, nil, fmt.Errorf("Unable to decode %s: %s", imageArchiveManifestFile, err)
+		}
+
+		imageIDs := make([]string, 0, len(manifest))
+		tags := make([]string, 0)
+		for _, image := range manifest {
+			// the config is either named '<id>.json' or 'blobs/sha256/<id>'
+			imageIDs = append(imageIDs, "sha256:"+strings.TrimSuffix(path.Base(image.Config), ".json"))
+			tags = append(tags, image.RepoTags...)
+		}
+		return imageIDs, tags, nil
+	}
+}
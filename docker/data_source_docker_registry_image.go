@@ -1,15 +1,10 @@
 package docker
 
 import (
-	"crypto/sha256"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
-	"os"
-	"strconv"
+	"log"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
@@ -25,198 +20,304 @@ func dataSourceDockerRegistryImage() *schema.Resource {
 				Optional: true,
 			},
 
+			"verify": imageSignatureVerifySchema(false),
+
+			"read_manifest_configs": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
 			"sha256_digest": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
-		},
-	}
-}
 
-func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
-	pullOpts := parseImageOptions(d.Get("name").(string))
-	authConfig := meta.(*ProviderConfig).AuthConfigs
+			"media_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 
-	// Use the official Docker Hub if a registry isn't specified
-	if pullOpts.Registry == "" {
-		pullOpts.Registry = "registry.hub.docker.com"
-	} else {
-		// Otherwise, filter the registry name out of the repo name
-		pullOpts.Repository = strings.Replace(pullOpts.Repository, pullOpts.Registry+"/", "", 1)
-	}
+			"compressed_size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
 
-	if pullOpts.Registry == "registry.hub.docker.com" {
-		// Docker prefixes 'library' to official images in the path; 'consul' becomes 'library/consul'
-		if !strings.Contains(pullOpts.Repository, "/") {
-			pullOpts.Repository = "library/" + pullOpts.Repository
-		}
-	}
+			"created": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 
-	if pullOpts.Tag == "" {
-		pullOpts.Tag = "latest"
-	}
+			"labels": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"env": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 
-	username := ""
-	password := ""
+			"entrypoint": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 
-	if auth, ok := authConfig.Configs[normalizeRegistryAddress(pullOpts.Registry)]; ok {
-		username = auth.Username
-		password = auth.Password
+			"manifests": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"platform": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"digest": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"media_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"size": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+
+						"compressed_size": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+
+						"created": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"labels": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+
+						"env": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+
+						"entrypoint": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
 	}
+}
 
-	digest, err := getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, false)
+// registryImageMetadata describes an image or a manifest list on a registry
+type registryImageMetadata struct {
+	Digest         string
+	MediaType      string
+	Platform       string
+	Size           int64
+	CompressedSize int64
+	Created        string
+	Labels         map[string]string
+	Env            []string
+	Entrypoint     []string
+	// the images of a manifest list
+	Manifests []registryImageMetadata
+}
+
+func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
+	registryClient := meta.(*ProviderConfig).RegistryClient
+	name := d.Get("name").(string)
+	ctx := context.Background()
 
+	image, err := getRemoteImageMetadata(ctx, registryClient, name, d.Get("read_manifest_configs").(bool))
 	if err != nil {
-		digest, err = getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, true)
+		if _, ok := err.(*unsupportedRegistryError); !ok {
+			return fmt.Errorf("Unable to read image %s: %s", name, err)
+		}
+		// Registries without support of the v2 manifest only provide the digest
+		log.Printf("[DEBUG] Unable to read manifest of image %s, reading its digest only: %s", name, err)
+		digest, err := getRemoteImageDigest(name, registryClient)
 		if err != nil {
-			return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
+			return err
+		}
+		image = &registryImageMetadata{Digest: digest}
+	}
+
+	if publicKey := getImageSignaturePublicKey(d.Get("verify").([]interface{})); publicKey != "" {
+		if err := verifyRegistryImageSignature(ctx, registryClient, parseRegistryImageName(name), image.Digest, publicKey); err != nil {
+			return fmt.Errorf("Unable to verify the signature of image %s: %s", name, err)
 		}
 	}
 
-	d.SetId(digest)
-	d.Set("sha256_digest", digest)
+	d.SetId(image.Digest)
+	d.Set("sha256_digest", image.Digest)
+	d.Set("media_type", image.MediaType)
+	d.Set("compressed_size", int(image.CompressedSize))
+	d.Set("created", image.Created)
+	d.Set("labels", image.Labels)
+	d.Set("env", image.Env)
+	d.Set("entrypoint", image.Entrypoint)
+	d.Set("manifests", flattenRegistryImageManifests(image.Manifests))
 
 	return nil
 }
 
-func getImageDigest(registry, image, tag, username, password string, fallback bool) (string, error) {
-	client := http.DefaultClient
-
-	// Allow insecure registries only for ACC tests
-	// cuz we don't have a valid certs for this case
-	if env, okEnv := os.LookupEnv("TF_ACC"); okEnv {
-		if i, errConv := strconv.Atoi(env); errConv == nil && i >= 1 {
-			cfg := &tls.Config{
-				InsecureSkipVerify: true,
-			}
-			client.Transport = &http.Transport{
-				TLSClientConfig: cfg,
-			}
-		}
+func flattenRegistryImageManifests(manifests []registryImageMetadata) []interface{} {
+	flattened := make([]interface{}, 0, len(manifests))
+	for _, m := range manifests {
+		flattened = append(flattened, map[string]interface{}{
+			"platform":        m.Platform,
+			"digest":          m.Digest,
+			"media_type":      m.MediaType,
+			"size":            int(m.Size),
+			"compressed_size": int(m.CompressedSize),
+			"created":         m.Created,
+			"labels":          m.Labels,
+			"env":             m.Env,
+			"entrypoint":      m.Entrypoint,
+		})
 	}
+	return flattened
+}
 
-	req, err := http.NewRequest("GET", "https://"+registry+"/v2/"+image+"/manifests/"+tag, nil)
+// getRemoteImageMetadata reads the manifest the image name points to on its registry and
+// the config of the image. For a manifest list, only the entries of the list are returned
+// unless readManifestConfigs is set, as reading each of the listed images takes two more
+// requests per platform, which count against the rate limit of e.g. the Docker Hub.
+func getRemoteImageMetadata(ctx context.Context, registryClient *registryClient, imageName string, readManifestConfigs bool) (*registryImageMetadata, error) {
+	pullOpts := parseRegistryImageName(imageName)
+	manifest, mediaType, digest, err := registryClient.getManifest(ctx, pullOpts.Registry, pullOpts.Repository, pullOpts.Tag)
 	if err != nil {
-		return "", fmt.Errorf("Error creating registry request: %s", err)
+		return nil, err
 	}
-
-	if username != "" {
-		req.SetBasicAuth(username, password)
+	if manifest.SchemaVersion == 1 {
+		return nil, &unsupportedRegistryError{fmt.Sprintf("Manifest %s of %s is of schema version 1", pullOpts.Tag, pullOpts.Repository)}
 	}
 
-	// We accept schema v2 manifests and manifest lists, and also OCI types
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
-
-	if fallback {
-		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
-		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v1+prettyjws")
+	image := &registryImageMetadata{
+		Digest:    digest,
+		MediaType: mediaType,
 	}
-
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return "", fmt.Errorf("Error during registry request: %s", err)
+	if !isManifestList(mediaType) {
+		if err := readRegistryImageConfig(ctx, registryClient, pullOpts, manifest, image); err != nil {
+			return nil, err
+		}
+		return image, nil
 	}
 
-	switch resp.StatusCode {
-	// Basic auth was valid or not needed
-	case http.StatusOK:
-		return getDigestFromResponse(resp)
-
-	// Either OAuth is required or the basic auth creds were invalid
-	case http.StatusUnauthorized:
-		if strings.HasPrefix(resp.Header.Get("www-authenticate"), "Bearer") {
-			auth := parseAuthHeader(resp.Header.Get("www-authenticate"))
-			params := url.Values{}
-			params.Set("service", auth["service"])
-			params.Set("scope", auth["scope"])
-			tokenRequest, err := http.NewRequest("GET", auth["realm"]+"?"+params.Encode(), nil)
-
-			if err != nil {
-				return "", fmt.Errorf("Error creating registry request: %s", err)
-			}
-
-			if username != "" {
-				tokenRequest.SetBasicAuth(username, password)
-			}
-
-			tokenResponse, err := client.Do(tokenRequest)
-
+	for _, descriptor := range manifest.Manifests {
+		entry := registryImageMetadata{
+			Digest:    descriptor.Digest,
+			MediaType: descriptor.MediaType,
+			Size:      descriptor.Size,
+		}
+		if descriptor.Platform != nil {
+			entry.Platform = strings.TrimSuffix(descriptor.Platform.OS+"/"+descriptor.Platform.Architecture+"/"+descriptor.Platform.Variant, "/")
+		}
+		if readManifestConfigs && !isManifestList(descriptor.MediaType) {
+			entryManifest, _, _, err := registryClient.getManifest(ctx, pullOpts.Registry, pullOpts.Repository, descriptor.Digest)
 			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
+				return nil, err
 			}
-
-			if tokenResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry: " + tokenResponse.Status)
+			if err := readRegistryImageConfig(ctx, registryClient, pullOpts, entryManifest, &entry); err != nil {
+				return nil, err
 			}
+		}
+		image.Manifests = append(image.Manifests, entry)
+	}
+	return image, nil
+}
 
-			body, err := ioutil.ReadAll(tokenResponse.Body)
-			if err != nil {
-				return "", fmt.Errorf("Error reading response body: %s", err)
-			}
+// readRegistryImageConfig sets the size of the layers of the image manifest
+// and the creation time, labels, env and entrypoint of its config
+func readRegistryImageConfig(ctx context.Context, registryClient *registryClient, pullOpts internalPullImageOptions, manifest *registryManifest, image *registryImageMetadata) error {
+	for _, layer := range manifest.Layers {
+		image.CompressedSize += layer.Size
+	}
+	if manifest.Config == nil {
+		return nil
+	}
 
-			token := &TokenResponse{}
-			err = json.Unmarshal(body, token)
-			if err != nil {
-				return "", fmt.Errorf("Error parsing OAuth token response: %s", err)
-			}
+	blob, err := registryClient.getBlob(ctx, pullOpts.Registry, pullOpts.Repository, manifest.Config.Digest)
+	if err != nil {
+		return fmt.Errorf("Unable to read config of image %s: %s", image.Digest, err)
+	}
+	config := &registryImageConfig{}
+	if err := json.Unmarshal(blob, config); err != nil {
+		return fmt.Errorf("Unable to parse config of image %s: %s", image.Digest, err)
+	}
 
-			req.Header.Set("Authorization", "Bearer "+token.Token)
-			digestResponse, err := client.Do(req)
+	image.Created = config.Created
+	image.Labels = config.Config.Labels
+	image.Env = config.Config.Env
+	image.Entrypoint = config.Config.Entrypoint
+	return nil
+}
 
-			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
-			}
+// getRemoteImageDigest returns the digest of the manifest the image name points
+// to on its registry, using the credentials configured for the registry
+func getRemoteImageDigest(imageName string, registryClient *registryClient) (string, error) {
+	pullOpts := parseRegistryImageName(imageName)
 
-			if digestResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry: " + digestResponse.Status)
-			}
+	ctx := context.Background()
+	digest, err := registryClient.getManifestDigest(ctx, pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, false)
 
-			return getDigestFromResponse(digestResponse)
+	if err != nil {
+		digest, err = registryClient.getManifestDigest(ctx, pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, true)
+		if err != nil {
+			return "", fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
 		}
-
-		return "", fmt.Errorf("Bad credentials: " + resp.Status)
-
-		// Some unexpected status was given, return an error
-	default:
-		return "", fmt.Errorf("Got bad response from registry: " + resp.Status)
 	}
-}
 
-type TokenResponse struct {
-	Token string
+	return digest, nil
 }
 
-// Parses key/value pairs from a WWW-Authenticate header
-func parseAuthHeader(header string) map[string]string {
-	parts := strings.SplitN(header, " ", 2)
-	parts = strings.Split(parts[1], ",")
-	opts := make(map[string]string)
-
-	for _, part := range parts {
-		vals := strings.SplitN(part, "=", 2)
-		key := vals[0]
-		val := strings.Trim(vals[1], "\", ")
-		opts[key] = val
+// parseRegistryImageName returns the registry, repository and tag or digest of
+// the image name as used by the registry API, e.g. 'library/alpine' on the Docker Hub
+func parseRegistryImageName(imageName string) internalPullImageOptions {
+	// A digest is used instead of the tag, e.g. 'alpine@sha256:...'
+	digest := ""
+	if i := strings.Index(imageName, "@"); i != -1 {
+		imageName, digest = imageName[:i], imageName[i+1:]
 	}
+	pullOpts := parseImageOptions(imageName)
 
-	return opts
-}
-
-func getDigestFromResponse(response *http.Response) (string, error) {
-	header := response.Header.Get("Docker-Content-Digest")
+	// Use the official Docker Hub if a registry isn't specified
+	if pullOpts.Registry == "" {
+		pullOpts.Registry = "registry.hub.docker.com"
+	} else {
+		// Otherwise, filter the registry name out of the repo name
+		pullOpts.Repository = strings.Replace(pullOpts.Repository, pullOpts.Registry+"/", "", 1)
+	}
 
-	if header == "" {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return "", fmt.Errorf("Error reading registry response body: %s", err)
+	if pullOpts.Registry == "registry.hub.docker.com" {
+		// Docker prefixes 'library' to official images in the path; 'consul' becomes 'library/consul'
+		if !strings.Contains(pullOpts.Repository, "/") {
+			pullOpts.Repository = "library/" + pullOpts.Repository
 		}
+	}
 
-		return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+	if digest != "" {
+		pullOpts.Tag = digest
+	} else if pullOpts.Tag == "" {
+		pullOpts.Tag = "latest"
 	}
 
-	return header, nil
+	return pullOpts
 }
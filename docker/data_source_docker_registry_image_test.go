@@ -2,13 +2,17 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
 var registryDigestRegexp = regexp.MustCompile(`\A[A-Za-z0-9_\+\.-]+:[A-Fa-f0-9]+\z`)
@@ -22,6 +26,10 @@ func TestAccDockerRegistryImage_basic(t *testing.T) {
 				Config: testAccDockerImageDataSourceConfig,
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestMatchResourceAttr("data.docker_registry_image.foo", "sha256_digest", registryDigestRegexp),
+					resource.TestCheckResourceAttr("data.docker_registry_image.foo", "media_type", "application/vnd.docker.distribution.manifest.list.v2+json"),
+					resource.TestMatchResourceAttr("data.docker_registry_image.foo", "manifests.0.digest", registryDigestRegexp),
+					resource.TestCheckResourceAttr("data.docker_registry_image.foo", "manifests.0.platform", "linux/amd64"),
+					resource.TestCheckResourceAttrSet("data.docker_registry_image.foo", "manifests.0.created"),
 				),
 			},
 		},
@@ -109,3 +117,137 @@ func TestGetDigestFromResponse(t *testing.T) {
 		t.Errorf("Expected digest calculated from body to be %s, but was %s", bodyDigest, digest)
 	}
 }
+
+func TestGetRemoteImageMetadata(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	config := registry.putBlob("foo/bar", `{"created":"2020-05-29T21:19:46.363518345Z","config":{"Labels":{"org.opencontainers.image.version":"1.0"},"Env":["PATH=/bin"],"Entrypoint":["/entrypoint.sh"]}}`)
+	amd64 := registry.putManifest("foo/bar", "", manifestMediaTypeV2, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": 100, "digest": "%s"},
+		"layers": [
+			{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 1000, "digest": "sha256:a"},
+			{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 234, "digest": "sha256:b"}
+		]
+	}`, config))
+	arm := registry.putManifest("foo/bar", "", manifestMediaTypeOCI, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 100, "digest": "%s"},
+		"layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "size": 42, "digest": "sha256:c"}]
+	}`, config))
+	list := registry.putManifest("foo/bar", "1.0", manifestMediaTypeList, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+		"manifests": [
+			{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 500, "digest": "%s", "platform": {"architecture": "amd64", "os": "linux"}},
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 400, "digest": "%s", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}}
+		]
+	}`, amd64, arm))
+
+	// only the entries of the list are read by default
+	registry.manifestGets = 0
+	image, err := getRemoteImageMetadata(context.Background(), registry.client(), registry.registry()+"/foo/bar:1.0", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if registry.manifestGets != 1 {
+		t.Fatalf("Expected only the manifest list to be read, got %d manifest requests", registry.manifestGets)
+	}
+	expectedEntries := &registryImageMetadata{
+		Digest:    list,
+		MediaType: manifestMediaTypeList,
+		Manifests: []registryImageMetadata{
+			{Digest: amd64, MediaType: manifestMediaTypeV2, Platform: "linux/amd64", Size: 500},
+			{Digest: arm, MediaType: manifestMediaTypeOCI, Platform: "linux/arm/v7", Size: 400},
+		},
+	}
+	if !reflect.DeepEqual(image, expectedEntries) {
+		t.Fatalf("Expected %#v, got %#v", expectedEntries, image)
+	}
+
+	image, err = getRemoteImageMetadata(context.Background(), registry.client(), registry.registry()+"/foo/bar:1.0", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	labels := map[string]string{"org.opencontainers.image.version": "1.0"}
+	expected := &registryImageMetadata{
+		Digest:    list,
+		MediaType: manifestMediaTypeList,
+		Manifests: []registryImageMetadata{
+			{
+				Digest:         amd64,
+				MediaType:      manifestMediaTypeV2,
+				Platform:       "linux/amd64",
+				Size:           500,
+				CompressedSize: 1234,
+				Created:        "2020-05-29T21:19:46.363518345Z",
+				Labels:         labels,
+				Env:            []string{"PATH=/bin"},
+				Entrypoint:     []string{"/entrypoint.sh"},
+			},
+			{
+				Digest:         arm,
+				MediaType:      manifestMediaTypeOCI,
+				Platform:       "linux/arm/v7",
+				Size:           400,
+				CompressedSize: 42,
+				Created:        "2020-05-29T21:19:46.363518345Z",
+				Labels:         labels,
+				Env:            []string{"PATH=/bin"},
+				Entrypoint:     []string{"/entrypoint.sh"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(image, expected) {
+		t.Fatalf("Expected %#v, got %#v", expected, image)
+	}
+
+	// a single image is read without manifests
+	image, err = getRemoteImageMetadata(context.Background(), registry.client(), registry.registry()+"/foo/bar@"+arm, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if image.Digest != arm || image.MediaType != manifestMediaTypeOCI || image.CompressedSize != 42 || len(image.Manifests) != 0 {
+		t.Fatalf("Unexpected image %#v", image)
+	}
+}
+
+func TestDockerRegistryImageReadFallback(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	v1 := registry.putManifest("foo/bar", "v1", manifestMediaTypeV1Signed, `{"schemaVersion":1,"name":"foo/bar","tag":"v1","fsLayers":[]}`)
+	// the config of the image is missing on the registry
+	registry.putManifest("foo/bar", "broken", manifestMediaTypeV2, `{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": 100, "digest": "sha256:a"},
+		"layers": []
+	}`)
+
+	read := func(tag string) (*schema.ResourceData, error) {
+		d := schema.TestResourceDataRaw(t, dataSourceDockerRegistryImage().Schema, map[string]interface{}{
+			"name": registry.registry() + "/foo/bar:" + tag,
+		})
+		return d, dataSourceDockerRegistryImageRead(d, &ProviderConfig{RegistryClient: registry.client()})
+	}
+
+	// schema v1 manifests only provide the digest
+	d, err := read("v1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if digest := d.Get("sha256_digest").(string); digest != v1 {
+		t.Fatalf("Expected the digest %s, got %s", v1, digest)
+	}
+
+	if _, err := read("broken"); err == nil || !strings.Contains(err.Error(), "Unable to read config of image") {
+		t.Fatalf("Expected the missing config to fail the read, got %v", err)
+	}
+	if _, err := read("missing"); err == nil {
+		t.Fatal("Expected the missing tag to fail the read")
+	}
+}
@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+const (
+	registryTagsSortSemver       = "semver"
+	registryTagsSortAlphabetical = "alphabetical"
+)
+
+func dataSourceDockerRegistryTags() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceDockerRegistryTagsRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "The name of the repository without tag, e.g. 'nginx' or 'registry.local:5000/foo'",
+				Required:    true,
+			},
+
+			"filter": {
+				Type:         schema.TypeString,
+				Description:  "A regular expression the tags have to match",
+				Optional:     true,
+				ValidateFunc: validateRegexp(),
+			},
+
+			"version_constraint": {
+				Type:         schema.TypeString,
+				Description:  "A version constraint the tags have to match, e.g. '~> 2.0'",
+				Optional:     true,
+				ValidateFunc: validateVersionConstraint(),
+			},
+
+			"sort": {
+				Type:         schema.TypeString,
+				Description:  "The order of the tags: 'semver' ignores tags which are not versions, 'alphabetical' keeps all tags",
+				Optional:     true,
+				Default:      registryTagsSortSemver,
+				ValidateFunc: validateStringMatchesPattern(`^(semver|alphabetical)$`),
+			},
+
+			"tags": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"tag": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"sha256_digest": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceDockerRegistryTagsRead(d *schema.ResourceData, meta interface{}) error {
+	registryClient := meta.(*ProviderConfig).RegistryClient
+	name := d.Get("name").(string)
+	pullOpts := parseRegistryImageName(name)
+
+	allTags, err := registryClient.listTags(context.Background(), pullOpts.Registry, pullOpts.Repository)
+	if err != nil {
+		return fmt.Errorf("Unable to list tags of %s: %s", name, err)
+	}
+
+	tags, err := selectRegistryTags(allTags, d.Get("filter").(string), d.Get("version_constraint").(string), d.Get("sort").(string))
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("None of the %d tags of %s matches", len(allTags), name)
+	}
+
+	// the last tag is the newest version
+	tag := tags[len(tags)-1]
+	log.Printf("[DEBUG] Selected tag %s of %s from %d matching tags", tag, name, len(tags))
+	digest, err := getRemoteImageDigest(pullOpts.Registry+"/"+pullOpts.Repository+":"+tag, registryClient)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(digest)
+	d.Set("tags", tags)
+	d.Set("tag", tag)
+	d.Set("sha256_digest", digest)
+
+	return nil
+}
+
+// selectRegistryTags returns the tags matching the filter and the version constraint, in
+// ascending order. Sorting by semver ignores the tags which are not versions, and without
+// a filter or a constraint pre-releases and variants like '2.1.0-rc1' or '2.2-alpine' as well.
+func selectRegistryTags(tags []string, filter string, constraint string, order string) ([]string, error) {
+	var filterRegexp *regexp.Regexp
+	if filter != "" {
+		var err error
+		if filterRegexp, err = regexp.Compile(filter); err != nil {
+			return nil, fmt.Errorf("Unable to parse filter %q: %s", filter, err)
+		}
+	}
+	var constraints version.Constraints
+	if constraint != "" {
+		var err error
+		if constraints, err = version.NewConstraint(constraint); err != nil {
+			return nil, fmt.Errorf("Unable to parse version constraint %q: %s", constraint, err)
+		}
+	}
+
+	selected := []string{}
+	versions := make(map[string]*version.Version)
+	for _, tag := range tags {
+		if filterRegexp != nil && !filterRegexp.MatchString(tag) {
+			continue
+		}
+		if constraints != nil || order == registryTagsSortSemver {
+			v, err := version.NewVersion(tag)
+			if err != nil {
+				continue
+			}
+			if constraints != nil && !constraints.Check(v) {
+				continue
+			}
+			// pre-releases are only selected if a filter or a constraint asks for them
+			if filterRegexp == nil && constraints == nil && v.Prerelease() != "" {
+				continue
+			}
+			versions[tag] = v
+		}
+		selected = append(selected, tag)
+	}
+
+	sort.Strings(selected)
+	if order == registryTagsSortSemver {
+		// equal versions like '2.1' and '2.1.0' stay sorted alphabetically
+		sort.SliceStable(selected, func(i, j int) bool {
+			return versions[selected[i]].LessThan(versions[selected[j]])
+		})
+	}
+	return selected, nil
+}
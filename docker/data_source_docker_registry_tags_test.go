@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccDockerRegistryTags_versionConstraint(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerRegistryTagsConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.docker_registry_tags.alpine", "tag", regexp.MustCompile(`\A3\.11\.\d+\z`)),
+					resource.TestCheckResourceAttr("data.docker_registry_tags.alpine", "tags.0", "3.11.0"),
+					resource.TestMatchResourceAttr("data.docker_registry_tags.alpine", "sha256_digest", registryDigestRegexp),
+				),
+			},
+		},
+	})
+}
+
+func TestSelectRegistryTags(t *testing.T) {
+	tags := []string{"1.9.0", "1.10.0", "2.0", "2.0.0", "2.1.0", "2.1.0-rc1", "2.10.3", "3.0.0", "latest", "2.2-alpine"}
+
+	cases := []struct {
+		filter     string
+		constraint string
+		order      string
+		expected   []string
+	}{
+		{
+			order:    registryTagsSortSemver,
+			expected: []string{"1.9.0", "1.10.0", "2.0", "2.0.0", "2.1.0", "2.10.3", "3.0.0"},
+		},
+		{
+			filter:   `^2\.`,
+			order:    registryTagsSortSemver,
+			expected: []string{"2.0", "2.0.0", "2.1.0-rc1", "2.1.0", "2.2-alpine", "2.10.3"},
+		},
+		{
+			constraint: "~> 2.0",
+			order:      registryTagsSortSemver,
+			expected:   []string{"2.0", "2.0.0", "2.1.0", "2.10.3"},
+		},
+		{
+			filter:     `^\d+\.\d+\.\d+$`,
+			constraint: ">= 1.10, < 3",
+			order:      registryTagsSortSemver,
+			expected:   []string{"1.10.0", "2.0.0", "2.1.0", "2.10.3"},
+		},
+		{
+			filter:   `^2\.`,
+			order:    registryTagsSortAlphabetical,
+			expected: []string{"2.0", "2.0.0", "2.1.0", "2.1.0-rc1", "2.10.3", "2.2-alpine"},
+		},
+		{
+			filter:   `^none$`,
+			order:    registryTagsSortAlphabetical,
+			expected: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		selected, err := selectRegistryTags(tags, c.filter, c.constraint, c.order)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(selected, c.expected) {
+			t.Fatalf("Expected %v for filter %q, constraint %q and order %q, got %v", c.expected, c.filter, c.constraint, c.order, selected)
+		}
+	}
+}
+
+const testAccDockerRegistryTagsConfig = `
+data "docker_registry_tags" "alpine" {
+	name               = "alpine"
+	filter             = "^3\\.11\\.\\d+$"
+	version_constraint = "~> 3.11.0"
+}
+`
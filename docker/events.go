@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+const defaultEventPollInterval = 1 * time.Second
+
+// eventWaitConfig configures how waitForEvents waits for a condition
+type eventWaitConfig struct {
+	// Filters selects the daemon events which trigger a re-evaluation
+	// of the condition, e.g. type=container and event=start
+	Filters filters.Args
+	// Target describes the awaited state, it is only used for the timeout error
+	Target []string
+	// Timeout is the maximum time to wait for the condition
+	Timeout time.Duration
+	// PollInterval is the interval the condition is re-evaluated in
+	// if the events stream of the daemon is not available. Default: 1s
+	PollInterval time.Duration
+	// Resync additionally re-evaluates the condition in the given interval while
+	// the events stream is available. This is needed for state changes which are
+	// not reported as events on the daemon we are talking to, e.g. swarm tasks on
+	// other nodes. Zero disables it.
+	Resync time.Duration
+}
+
+// eventWaitCondition reports if the awaited state is reached. An error aborts the wait.
+type eventWaitCondition func() (bool, error)
+
+// waitForEvents evaluates the condition once and then each time the daemon emits an
+// event matching the configured filters, until the condition is met, it returns an error
+// or the timeout is reached. If the events stream is not available it falls back
+// to evaluating the condition in the configured poll interval.
+// On timeout a *resource.TimeoutError is returned.
+func waitForEvents(ctx context.Context, client *client.Client, conf eventWaitConfig, condition eventWaitCondition) error {
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = defaultEventPollInterval
+	}
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
+	}
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+
+	// the subscription is established when Events returns, so no event
+	// between the first evaluation of the condition and the wait is lost
+	messages, errs := client.Events(streamCtx, types.EventsOptions{
+		Filters: conf.Filters,
+	})
+
+	var poll, resync <-chan time.Time
+	if conf.Resync > 0 {
+		ticker := time.NewTicker(conf.Resync)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return waitContextError(ctx, conf)
+		case msg := <-messages:
+			log.Printf("[DEBUG] Received %s event '%s' for '%s'", msg.Type, msg.Action, msg.Actor.ID)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return waitContextError(ctx, conf)
+			}
+			log.Printf("[WARN] Docker events stream is not available, falling back to polling every %v: %v", conf.PollInterval, err)
+			messages, errs = nil, nil
+			ticker := time.NewTicker(conf.PollInterval)
+			defer ticker.Stop()
+			poll = ticker.C
+			resync = nil
+		case <-poll:
+		case <-resync:
+		}
+	}
+}
+
+// waitContextError converts the error of the exceeded wait context
+func waitContextError(ctx context.Context, conf eventWaitConfig) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &resource.TimeoutError{
+			ExpectedState: conf.Target,
+			Timeout:       conf.Timeout,
+		}
+	}
+	return ctx.Err()
+}
+
+// eventFilters creates the filters for the events of the given type. If
+// the id is empty all objects of the type are matched.
+func eventFilters(eventType, id string, actions ...string) filters.Args {
+	args := filters.NewArgs(filters.Arg("type", eventType))
+	if id != "" {
+		args.Add(eventType, id)
+	}
+	for _, action := range actions {
+		args.Add("event", action)
+	}
+	return args
+}
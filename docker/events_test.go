@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func newEventsTestClient(t *testing.T, handler http.HandlerFunc) (*client.Client, func()) {
+	server := httptest.NewServer(handler)
+	c, err := client.NewClientWithOpts(
+		client.WithHost("tcp://"+server.Listener.Addr().String()),
+		client.WithHTTPClient(server.Client()),
+		client.WithVersion("1.40"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, server.Close
+}
+
+func TestWaitForEvents_triggeredByEvent(t *testing.T) {
+	sendEvent := make(chan struct{})
+	c, closeServer := newEventsTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/events") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.Contains(r.URL.Query().Get("filters"), "destroy") {
+			t.Errorf("expected the event filter in the query, got %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-sendEvent:
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(events.Message{Type: "container", Action: "destroy", Actor: events.Actor{ID: "foo"}})
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	defer closeServer()
+
+	calls := 0
+	err := waitForEvents(context.Background(), c, eventWaitConfig{
+		Filters:      eventFilters("container", "", "destroy"),
+		Timeout:      10 * time.Second,
+		PollInterval: time.Hour,
+	}, func() (bool, error) {
+		calls++
+		if calls == 1 {
+			close(sendEvent)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the condition to be evaluated 2 times, got %d", calls)
+	}
+}
+
+func TestWaitForEvents_fallbackToPolling(t *testing.T) {
+	c, closeServer := newEventsTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer closeServer()
+
+	calls := 0
+	err := waitForEvents(context.Background(), c, eventWaitConfig{
+		Filters:      eventFilters("network", "foo", "disconnect"),
+		Timeout:      10 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	}, func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected the condition to be evaluated 3 times, got %d", calls)
+	}
+}
+
+func TestWaitForEvents_timeout(t *testing.T) {
+	c, closeServer := newEventsTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	defer closeServer()
+
+	err := waitForEvents(context.Background(), c, eventWaitConfig{
+		Filters: eventFilters("service", "foo"),
+		Target:  []string{"completed"},
+		Timeout: 100 * time.Millisecond,
+	}, func() (bool, error) {
+		return false, nil
+	})
+	if _, ok := err.(*resource.TimeoutError); !ok {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "timeout while waiting for state") {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+}
@@ -101,19 +101,25 @@ func Provider() terraform.ResourceProvider {
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"docker_container":      resourceDockerContainer(),
-			"docker_image":          resourceDockerImage(),
-			"docker_registry_image": resourceDockerRegistryImage(),
-			"docker_network":        resourceDockerNetwork(),
-			"docker_volume":         resourceDockerVolume(),
-			"docker_config":         resourceDockerConfig(),
-			"docker_secret":         resourceDockerSecret(),
-			"docker_service":        resourceDockerService(),
+			"docker_container":           resourceDockerContainer(),
+			"docker_image":               resourceDockerImage(),
+			"docker_image_archive":       resourceDockerImageArchive(),
+			"docker_image_load":          resourceDockerImageLoad(),
+			"docker_registry_image":      resourceDockerRegistryImage(),
+			"docker_registry_image_copy": resourceDockerRegistryImageCopy(),
+			"docker_network":             resourceDockerNetwork(),
+			"docker_volume":              resourceDockerVolume(),
+			"docker_config":              resourceDockerConfig(),
+			"docker_secret":              resourceDockerSecret(),
+			"docker_service":             resourceDockerService(),
+			"docker_tag":                 resourceDockerTag(),
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
 			"docker_registry_image": dataSourceDockerRegistryImage(),
 			"docker_network":        dataSourceDockerNetwork(),
+			"docker_image_archive":  dataSourceDockerImageArchive(),
+			"docker_registry_tags":  dataSourceDockerRegistryTags(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -151,8 +157,9 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	}
 
 	providerConfig := ProviderConfig{
-		DockerClient: client,
-		AuthConfigs:  authConfigs,
+		DockerClient:   client,
+		AuthConfigs:    authConfigs,
+		RegistryClient: newRegistryClient(authConfigs),
 	}
 
 	return &providerConfig, nil
@@ -200,6 +207,8 @@ func providerSetToRegistryAuth(authSet *schema.Set) (*AuthConfigs, error) {
 			}
 			authConfig.Username = authFileConfig.Username
 			authConfig.Password = authFileConfig.Password
+			authConfig.IdentityToken = authFileConfig.IdentityToken
+			authConfig.RegistryToken = authFileConfig.RegistryToken
 
 			// As last step we check if a config file path is given
 		} else if configFile, ok := auth["config_file"]; ok && configFile.(string) != "" {
@@ -229,6 +238,8 @@ func providerSetToRegistryAuth(authSet *schema.Set) (*AuthConfigs, error) {
 			}
 			authConfig.Username = authFileConfig.Username
 			authConfig.Password = authFileConfig.Password
+			authConfig.IdentityToken = authFileConfig.IdentityToken
+			authConfig.RegistryToken = authFileConfig.RegistryToken
 		}
 
 		authConfigs.Configs[authConfig.ServerAddress] = authConfig
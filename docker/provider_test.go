@@ -39,6 +39,31 @@ func TestAccDockerProvider_WithIncompleteRegistryAuth(t *testing.T) {
 	})
 }
 
+func TestProviderSetToRegistryAuthIdentityToken(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().(*schema.Provider).Schema, map[string]interface{}{
+		"registry_auth": []interface{}{
+			map[string]interface{}{
+				"address":             "registry.example.com",
+				"username":            "",
+				"password":            "",
+				"config_file_content": `{"auths":{"registry.example.com":{"auth":"PHRva2VuPjo=","identitytoken":"fooIdentityToken"}}}`,
+			},
+		},
+	})
+
+	authConfigs, err := providerSetToRegistryAuth(d.Get("registry_auth").(*schema.Set))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	authConfig, ok := authConfigs.Configs[normalizeRegistryAddress("registry.example.com")]
+	if !ok {
+		t.Fatalf("Expected an auth config for registry.example.com, got %v", authConfigs.Configs)
+	}
+	if authConfig.Username != "<token>" || authConfig.IdentityToken != "fooIdentityToken" {
+		t.Fatalf("Expected the identity token of the config file, got %+v", authConfig)
+	}
+}
+
 func TestProvider_impl(t *testing.T) {
 	var _ terraform.ResourceProvider = Provider()
 }
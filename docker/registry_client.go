@@ -0,0 +1,805 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// registryTokenDefaultExpiry is the lifetime of a token
+	// without expiry, as defined by the token specification
+	registryTokenDefaultExpiry = 60 * time.Second
+	// registryTokenExpiryMargin is subtracted from the lifetime of
+	// a token so it does not expire while a request is sent
+	registryTokenExpiryMargin = 10 * time.Second
+	// registryTokenClientID identifies the provider when exchanging identity tokens
+	registryTokenClientID = "terraform-provider-docker"
+
+	manifestMediaTypeV1Signed = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+)
+
+const (
+	manifestMediaTypeV2       = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestMediaTypeList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	manifestMediaTypeOCI      = "application/vnd.oci.image.manifest.v1+json"
+	manifestMediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestMediaTypes are the accepted manifest types: schema v2
+// manifests and manifest lists, and also OCI types
+var manifestMediaTypes = []string{
+	manifestMediaTypeV2,
+	manifestMediaTypeList,
+	manifestMediaTypeOCI,
+	manifestMediaTypeOCIIndex,
+}
+
+// registryManifest is an image manifest or a manifest list of a registry,
+// either in the Docker schema v2 or the OCI format
+type registryManifest struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType,omitempty"`
+	Config        *registryDescriptor  `json:"config,omitempty"`
+	Layers        []registryDescriptor `json:"layers,omitempty"`
+	Manifests     []registryDescriptor `json:"manifests,omitempty"`
+}
+
+// unsupportedRegistryError is returned if the registry does not support a request, e.g. if it
+// provides no manifest of the accepted media types for a reference or cannot list tags
+type unsupportedRegistryError struct {
+	reason string
+}
+
+func (e *unsupportedRegistryError) Error() string {
+	return e.reason
+}
+
+// registryDescriptor references a blob or a manifest by its digest
+type registryDescriptor struct {
+	MediaType string            `json:"mediaType"`
+	Size      int64             `json:"size"`
+	Digest    string            `json:"digest"`
+	Platform  *registryPlatform `json:"platform,omitempty"`
+	// e.g. the signature of a layer of a cosign signature manifest
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type registryPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// registryImageConfig is the part of the image config blob read by the provider
+type registryImageConfig struct {
+	Created string `json:"created"`
+	Config  struct {
+		Labels     map[string]string `json:"Labels"`
+		Env        []string          `json:"Env"`
+		Entrypoint []string          `json:"Entrypoint"`
+	} `json:"config"`
+}
+
+// isManifestList checks whether the media type is a manifest list or an OCI index
+func isManifestList(mediaType string) bool {
+	return mediaType == manifestMediaTypeList || mediaType == manifestMediaTypeOCIIndex
+}
+
+// registryClient performs requests against the HTTP API of Docker registries
+// with the credentials of the provider. It remembers whether a repository
+// requires basic or bearer authentication and caches the bearer tokens per
+// realm, service and scope until they expire, so plans with many registry
+// lookups do not request a new token for each of them.
+type registryClient struct {
+	httpClient  *http.Client
+	authConfigs *AuthConfigs
+
+	mu sync.Mutex
+	// the last authentication challenge by registry, repository and scope, i.e. pull or push
+	challenges map[string]authChallenge
+	tokens     map[string]registryToken
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+type registryToken struct {
+	token   string
+	expires time.Time
+}
+
+// TokenResponse is the response of a token server
+type TokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func newRegistryClient(authConfigs *AuthConfigs) *registryClient {
+	httpClient := &http.Client{}
+
+	// Allow insecure registries only for ACC tests
+	// cuz we don't have a valid certs for this case
+	if env, okEnv := os.LookupEnv("TF_ACC"); okEnv {
+		if i, errConv := strconv.Atoi(env); errConv == nil && i >= 1 {
+			cfg := &tls.Config{
+				InsecureSkipVerify: true,
+			}
+			httpClient.Transport = &http.Transport{
+				TLSClientConfig: cfg,
+			}
+		}
+	}
+
+	return &registryClient{
+		httpClient:  httpClient,
+		authConfigs: authConfigs,
+		challenges:  make(map[string]authChallenge),
+		tokens:      make(map[string]registryToken),
+	}
+}
+
+// do sends a request to the path of the registry API, e.g. '/v2/foo/manifests/latest', or to
+// an absolute URL returned by the registry, and authenticates it for the repository. A request
+// with a body which is not a *bytes.Reader is not retried after authentication, so the
+// authentication of its scope should have been negotiated by a previous request. The
+// caller has to close the body of the response.
+func (c *registryClient) do(ctx context.Context, registry string, repository string, method string, path string, header http.Header, body io.Reader) (*http.Response, error) {
+	// the challenge is shared by the requests of a scope, so e.g. the streamed
+	// PUT of a blob upload is authorized by the challenge of the POST starting it
+	challengeKey := registry + "/" + repository + ":push"
+	if method == http.MethodGet || method == http.MethodHead {
+		challengeKey = registry + "/" + repository + ":pull"
+	}
+
+	requestURL := normalizeRegistryAddress(registry) + path
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		requestURL = path
+	}
+
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(method, requestURL, body)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating registry request: %s", err)
+		}
+		req = req.WithContext(ctx)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if length := header.Get("Content-Length"); length != "" {
+			if req.ContentLength, err = strconv.ParseInt(length, 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid Content-Length %q: %s", length, err)
+			}
+		}
+
+		c.mu.Lock()
+		challenge, ok := c.challenges[challengeKey]
+		c.mu.Unlock()
+		if err := c.authorize(ctx, req, registry, challenge, ok); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("Error during registry request: %s", err)
+		}
+		return resp, nil
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Either authentication is required, the credentials were invalid or the
+	// cached token expired, so the request is retried once for the challenge
+	challenge, ok := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+
+	c.mu.Lock()
+	c.challenges[challengeKey] = challenge
+	delete(c.tokens, c.tokenKey(registry, challenge))
+	c.mu.Unlock()
+
+	if body != nil {
+		reader, ok := body.(*bytes.Reader)
+		if !ok {
+			return resp, nil
+		}
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+
+	return send()
+}
+
+// authorize adds the credentials for the challenge to the request. Without
+// a known challenge, the credentials are sent as basic authentication.
+func (c *registryClient) authorize(ctx context.Context, req *http.Request, registry string, challenge authChallenge, known bool) error {
+	// a registry token is sent as is, like by the Docker CLI
+	if registryToken := c.authConfig(registry).RegistryToken; registryToken != "" {
+		req.Header.Set("Authorization", "Bearer "+registryToken)
+		return nil
+	}
+
+	username, password := c.credentials(registry)
+	if !known || challenge.scheme == "basic" {
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		return nil
+	}
+
+	token, err := c.token(ctx, registry, challenge)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// token returns a cached token for the bearer challenge or requests a new one
+func (c *registryClient) token(ctx context.Context, registry string, challenge authChallenge) (string, error) {
+	key := c.tokenKey(registry, challenge)
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+
+	tokenRequest, err := c.newTokenRequest(registry, challenge)
+	if err != nil {
+		return "", fmt.Errorf("Error creating registry request: %s", err)
+	}
+	tokenRequest = tokenRequest.WithContext(ctx)
+
+	tokenResponse, err := c.httpClient.Do(tokenRequest)
+	if err != nil {
+		return "", fmt.Errorf("Error during registry request: %s", err)
+	}
+	defer tokenResponse.Body.Close()
+
+	if tokenResponse.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Got bad response from registry: " + tokenResponse.Status)
+	}
+
+	body, err := ioutil.ReadAll(tokenResponse.Body)
+	if err != nil {
+		return "", fmt.Errorf("Error reading response body: %s", err)
+	}
+
+	response := &TokenResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return "", fmt.Errorf("Error parsing OAuth token response: %s", err)
+	}
+	token := response.Token
+	if token == "" {
+		token = response.AccessToken
+	}
+	expiresIn := registryTokenDefaultExpiry
+	if response.ExpiresIn > 0 {
+		expiresIn = time.Duration(response.ExpiresIn) * time.Second
+	}
+
+	log.Printf("[DEBUG] Got registry token for %s, scope '%s', valid for %s", challenge.params["realm"], challenge.params["scope"], expiresIn)
+	c.mu.Lock()
+	c.tokens[key] = registryToken{
+		token:   token,
+		expires: time.Now().Add(expiresIn - registryTokenExpiryMargin),
+	}
+	c.mu.Unlock()
+	return token, nil
+}
+
+// newTokenRequest returns the request of a token for the bearer challenge. An identity token
+// is exchanged like an OAuth refresh token, as by the Docker CLI, otherwise the username
+// and password are sent as basic authentication.
+func (c *registryClient) newTokenRequest(registry string, challenge authChallenge) (*http.Request, error) {
+	auth := c.authConfig(registry)
+	if auth.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    []string{"refresh_token"},
+			"refresh_token": []string{auth.IdentityToken},
+			"client_id":     []string{registryTokenClientID},
+		}
+		if service := challenge.params["service"]; service != "" {
+			form.Set("service", service)
+		}
+		if scope := challenge.params["scope"]; scope != "" {
+			form.Set("scope", scope)
+		}
+		req, err := http.NewRequest(http.MethodPost, challenge.params["realm"], strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	params := url.Values{}
+	if service := challenge.params["service"]; service != "" {
+		params.Set("service", service)
+	}
+	for _, scope := range strings.Fields(challenge.params["scope"]) {
+		params.Add("scope", scope)
+	}
+	req, err := http.NewRequest(http.MethodGet, challenge.params["realm"]+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	return req, nil
+}
+
+func (c *registryClient) tokenKey(registry string, challenge authChallenge) string {
+	username, _ := c.credentials(registry)
+	return strings.Join([]string{challenge.params["realm"], challenge.params["service"], challenge.params["scope"], username}, "|")
+}
+
+// credentials returns the username and password configured for the registry
+func (c *registryClient) credentials(registry string) (string, string) {
+	auth := c.authConfig(registry)
+	return auth.Username, auth.Password
+}
+
+// authConfig returns the auth config of the registry, which is empty if it is not configured
+func (c *registryClient) authConfig(registry string) types.AuthConfig {
+	if c.authConfigs == nil {
+		return types.AuthConfig{}
+	}
+	return c.authConfigs.Configs[normalizeRegistryAddress(registry)]
+}
+
+// getManifestDigest returns the digest of the manifest of the reference, which is a tag or
+// digest. With fallback the signed v1 manifest is requested for registries without v2 support.
+func (c *registryClient) getManifestDigest(ctx context.Context, registry string, repository string, reference string, fallback bool) (string, error) {
+	header := http.Header{"Accept": manifestMediaTypes}
+	if fallback {
+		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
+		header = http.Header{"Accept": []string{manifestMediaTypeV1Signed}}
+	}
+
+	resp, err := c.do(ctx, registry, repository, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, header, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return getDigestFromResponse(resp)
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return "", fmt.Errorf("Got bad response from registry: " + resp.Status)
+	}
+}
+
+// headManifestDigest returns the digest of the manifest of the reference like getManifestDigest,
+// but with a HEAD request, which e.g. does not count towards the rate limit of the Docker Hub
+func (c *registryClient) headManifestDigest(ctx context.Context, registry string, repository string, reference string) (string, error) {
+	resp, err := c.do(ctx, registry, repository, http.MethodHead, "/v2/"+repository+"/manifests/"+reference, http.Header{"Accept": manifestMediaTypes}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+	case http.StatusNotFound:
+		return "", &unsupportedRegistryError{"Got bad response from registry: " + resp.Status}
+	default:
+		return "", fmt.Errorf("Got bad response from registry: " + resp.Status)
+	}
+
+	// the digest is only computed from the body without the header
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	return c.getManifestDigest(ctx, registry, repository, reference, false)
+}
+
+// getManifest returns the manifest of the reference with its media type and digest
+func (c *registryClient) getManifest(ctx context.Context, registry string, repository string, reference string) (*registryManifest, string, string, error) {
+	manifest, _, mediaType, digest, err := c.getRawManifest(ctx, registry, repository, reference)
+	return manifest, mediaType, digest, err
+}
+
+// getRawManifest returns the manifest of the reference, its content as stored
+// on the registry, its media type and its digest
+func (c *registryClient) getRawManifest(ctx context.Context, registry string, repository string, reference string) (*registryManifest, []byte, string, string, error) {
+	resp, err := c.do(ctx, registry, repository, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, http.Header{"Accept": manifestMediaTypes}, nil)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, nil, "", "", fmt.Errorf("Bad credentials: " + resp.Status)
+	case http.StatusNotFound:
+		return nil, nil, "", "", &unsupportedRegistryError{"Got bad response from registry: " + resp.Status}
+	default:
+		return nil, nil, "", "", fmt.Errorf("Got bad response from registry: " + resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, "", "", fmt.Errorf("Error reading registry response body: %s", err)
+	}
+	manifest := &registryManifest{}
+	if err := json.Unmarshal(body, manifest); err != nil {
+		return nil, nil, "", "", fmt.Errorf("Error parsing manifest %s of %s: %s", reference, repository, err)
+	}
+
+	// OCI manifests do not need to contain their media type
+	mediaType := manifest.MediaType
+	if mediaType == "" {
+		mediaType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	return manifest, body, mediaType, digest, nil
+}
+
+// putManifest uploads the manifest under the reference and returns its digest
+func (c *registryClient) putManifest(ctx context.Context, registry string, repository string, reference string, mediaType string, content []byte) (string, error) {
+	resp, err := c.do(ctx, registry, repository, http.MethodPut, "/v2/"+repository+"/manifests/"+reference, http.Header{"Content-Type": []string{mediaType}}, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return "", fmt.Errorf("Got bad response from registry for manifest %s: %s%s", reference, resp.Status, readRegistryErrors(resp.Body))
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content)), nil
+}
+
+// blobExists checks whether the repository contains the blob
+func (c *registryClient) blobExists(ctx context.Context, registry string, repository string, digest string) (bool, error) {
+	resp, err := c.do(ctx, registry, repository, http.MethodHead, "/v2/"+repository+"/blobs/"+digest, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusUnauthorized:
+		return false, fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return false, fmt.Errorf("Got bad response from registry for blob %s: %s", digest, resp.Status)
+	}
+}
+
+// startBlobUpload starts the upload of a blob and returns the location to upload it to. If a
+// repository to mount from is given, the registry may mount the blob of the other repository
+// instead, in which case it is not uploaded and the location is empty.
+func (c *registryClient) startBlobUpload(ctx context.Context, registry string, repository string, digest string, mountFrom string) (string, error) {
+	path := "/v2/" + repository + "/blobs/uploads/"
+	if mountFrom != "" {
+		path += "?" + url.Values{"mount": []string{digest}, "from": []string{mountFrom}}.Encode()
+	}
+	resp, err := c.do(ctx, registry, repository, http.MethodPost, path, http.Header{"Content-Length": []string{"0"}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		log.Printf("[DEBUG] Mounted blob %s from %s into %s", digest, mountFrom, repository)
+		return "", nil
+	case http.StatusAccepted:
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return "", fmt.Errorf("Registry did not return the location to upload blob %s to", digest)
+		}
+		return resolveRegistryLocation(resp.Request.URL, location)
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return "", fmt.Errorf("Got bad response from registry for blob %s: %s%s", digest, resp.Status, readRegistryErrors(resp.Body))
+	}
+}
+
+// uploadBlob uploads the content of the blob in a single request
+// to the location returned by startBlobUpload
+func (c *registryClient) uploadBlob(ctx context.Context, registry string, repository string, location string, digest string, size int64, content io.Reader) error {
+	uploadURL, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("Unable to parse upload location %q: %s", location, err)
+	}
+	query := uploadURL.Query()
+	query.Set("digest", digest)
+	uploadURL.RawQuery = query.Encode()
+
+	header := http.Header{
+		"Content-Type":   []string{"application/octet-stream"},
+		"Content-Length": []string{strconv.FormatInt(size, 10)},
+	}
+	resp, err := c.do(ctx, registry, repository, http.MethodPut, uploadURL.String(), header, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return fmt.Errorf("Got bad response from registry for blob %s: %s%s", digest, resp.Status, readRegistryErrors(resp.Body))
+	}
+}
+
+// openBlob returns a reader of the content of the blob with the digest
+func (c *registryClient) openBlob(ctx context.Context, registry string, repository string, digest string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, registry, repository, http.MethodGet, "/v2/"+repository+"/blobs/"+digest, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Got bad response from registry for blob %s: %s", digest, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// getBlob returns the content of the blob with the digest
+func (c *registryClient) getBlob(ctx context.Context, registry string, repository string, digest string) ([]byte, error) {
+	blob, err := c.openBlob(ctx, registry, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	body, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading registry response body: %s", err)
+	}
+	if actual := fmt.Sprintf("sha256:%x", sha256.Sum256(body)); strings.HasPrefix(digest, "sha256:") && actual != digest {
+		return nil, fmt.Errorf("Digest of blob %s does not match, got %s", digest, actual)
+	}
+	return body, nil
+}
+
+// listTags returns the tags of the repository, following the
+// pagination of the registry with the 'Link' header
+func (c *registryClient) listTags(ctx context.Context, registry string, repository string) ([]string, error) {
+	var tags []string
+	path := "/v2/" + repository + "/tags/list"
+	for path != "" {
+		resp, err := c.do(ctx, registry, repository, http.MethodGet, path, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			resp.Body.Close()
+			return nil, fmt.Errorf("Bad credentials: " + resp.Status)
+		case http.StatusNotFound, http.StatusMethodNotAllowed:
+			resp.Body.Close()
+			return nil, &unsupportedRegistryError{"Got bad response from registry: " + resp.Status}
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("Got bad response from registry: " + resp.Status)
+		}
+
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing tags of %s: %s", repository, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		path, err = parseNextLink(resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// deleteManifest deletes the manifest of the reference. A missing manifest is not an error.
+func (c *registryClient) deleteManifest(ctx context.Context, registry string, repository string, reference string, fallback bool) error {
+	header := http.Header{"Accept": manifestMediaTypes}
+	if fallback {
+		header = http.Header{"Accept": []string{manifestMediaTypeV1Signed}}
+	}
+
+	resp, err := c.do(ctx, registry, repository, http.MethodDelete, "/v2/"+repository+"/manifests/"+reference, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNotFound:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bad credentials: " + resp.Status)
+	default:
+		return fmt.Errorf("Got bad response from registry: " + resp.Status)
+	}
+}
+
+// parseAuthChallenge parses the scheme and parameters of a WWW-Authenticate header
+func parseAuthChallenge(header string) (authChallenge, bool) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if scheme != "basic" && scheme != "bearer" {
+		return authChallenge{}, false
+	}
+	challenge := authChallenge{
+		scheme: scheme,
+		params: make(map[string]string),
+	}
+	if len(parts) == 2 {
+		challenge.params = parseAuthHeader(header)
+	}
+	return challenge, challenge.scheme == "basic" || challenge.params["realm"] != ""
+}
+
+// Parses key/value pairs from a WWW-Authenticate header. Values may be
+// quoted and contain commas, e.g. scope="repository:foo:pull,push"
+func parseAuthHeader(header string) map[string]string {
+	opts := make(map[string]string)
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) < 2 {
+		return opts
+	}
+
+	rest := parts[1]
+	for {
+		rest = strings.TrimLeft(rest, ", ")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return opts
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value = b.String()
+			if i < len(rest) {
+				i++
+			}
+			rest = rest[i:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+		opts[key] = value
+	}
+}
+
+// resolveRegistryLocation returns the absolute URL of a Location
+// header, which may be relative to the URL of the request
+func resolveRegistryLocation(requestURL *url.URL, location string) (string, error) {
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse location %q: %s", location, err)
+	}
+	return requestURL.ResolveReference(locationURL).String(), nil
+}
+
+// readRegistryErrors returns the errors of the body of a failed
+// registry response, e.g. ': BLOB_UNKNOWN: blob unknown to registry'
+func readRegistryErrors(body io.Reader) string {
+	response := struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return ""
+	}
+	var message string
+	for _, e := range response.Errors {
+		message += fmt.Sprintf(": %s: %s", e.Code, e.Message)
+	}
+	return message
+}
+
+// parseNextLink returns the path and query of the 'next' URL of a Link
+// header, e.g. '</v2/foo/tags/list?n=100&last=bar>; rel="next"'
+func parseNextLink(header string) (string, error) {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if rel := strings.Replace(strings.TrimSpace(param), " ", "", -1); rel != `rel="next"` && rel != "rel=next" {
+				continue
+			}
+			u, err := url.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return "", fmt.Errorf("Unable to parse Link header %q: %s", header, err)
+			}
+			return u.RequestURI(), nil
+		}
+	}
+	return "", nil
+}
+
+// getDigestFromResponse returns the Docker-Content-Digest of the
+// response or the SHA256 digest of its body if the header is missing
+func getDigestFromResponse(response *http.Response) (string, error) {
+	header := response.Header.Get("Docker-Content-Digest")
+
+	if header == "" {
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return "", fmt.Errorf("Error reading registry response body: %s", err)
+		}
+
+		return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+	}
+
+	return header, nil
+}
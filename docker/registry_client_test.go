@@ -0,0 +1,498 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestRegistryClientCachesTokens(t *testing.T) {
+	manifest := `{"schemaVersion":2}`
+	tokenRequests := 0
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if scope := r.URL.Query().Get("scope"); scope != "repository:foo/bar:pull" {
+				t.Errorf("Unexpected scope %q", scope)
+			}
+			tokenRequests++
+			fmt.Fprint(w, `{"token":"t0ken","expires_in":300}`)
+		case "/v2/foo/bar/manifests/latest":
+			if r.Header.Get("Authorization") != "Bearer t0ken" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:foo/bar:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			// without Docker-Content-Digest the digest is computed from the body
+			fmt.Fprint(w, manifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := newRegistryClient(&AuthConfigs{
+		Configs: map[string]types.AuthConfig{
+			server.URL: {Username: "user", Password: "secret"},
+		},
+	})
+	c.httpClient = server.Client()
+
+	for i := 0; i < 2; i++ {
+		digest, err := c.getManifestDigest(context.Background(), server.URL, "foo/bar", "latest", false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := "sha256:bafebd36189ad3688b7b3915ea55d461e0bfcfbdde11e54b0a123999fb6be50f"
+		if digest != expected {
+			t.Fatalf("Expected digest %s, got %s", expected, digest)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("Expected the token to be requested once, but was requested %d times", tokenRequests)
+	}
+}
+
+func TestRegistryClientTokenCredentials(t *testing.T) {
+	manifest := `{"schemaVersion":2}`
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			// the identity token is exchanged like an OAuth refresh token
+			if r.Method != http.MethodPost || r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != "id-t0ken" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if scope := r.PostFormValue("scope"); scope != "repository:foo/bar:pull" {
+				t.Errorf("Unexpected scope %q", scope)
+			}
+			fmt.Fprint(w, `{"access_token":"access-t0ken","expires_in":300}`)
+		case "/v2/foo/bar/manifests/latest":
+			if auth := r.Header.Get("Authorization"); auth != "Bearer access-t0ken" && auth != "Bearer registry-t0ken" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:foo/bar:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, manifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, auth := range []types.AuthConfig{
+		{Username: "<token>", IdentityToken: "id-t0ken"},
+		{RegistryToken: "registry-t0ken"},
+	} {
+		c := newRegistryClient(&AuthConfigs{
+			Configs: map[string]types.AuthConfig{server.URL: auth},
+		})
+		c.httpClient = server.Client()
+
+		if _, err := c.getManifestDigest(context.Background(), server.URL, "foo/bar", "latest", false); err != nil {
+			t.Fatalf("Unexpected error for auth config %+v: %s", auth, err)
+		}
+	}
+}
+
+func TestRegistryClientListTags(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	expected := []string{"1.0", "1.1", "2.0", "latest"}
+	for _, tag := range expected {
+		registry.putManifest("foo", tag, manifestMediaTypeV2, `{"schemaVersion":2,"tag":"`+tag+`"}`)
+	}
+	// the first page lists the first two tags only
+	registry.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v2/foo/tags/list" && req.URL.RawQuery == "" {
+			req.URL.RawQuery = "n=2"
+		}
+		registry.serveHTTP(w, req)
+	})
+
+	tags, err := registry.client().listTags(context.Background(), registry.registry(), "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Expected tags %v, got %v", expected, tags)
+	}
+}
+
+func TestParseNextLink(t *testing.T) {
+	cases := map[string]string{
+		"": "",
+		`</v2/foo/tags/list?n=100&last=bar>; rel="next"`:                               "/v2/foo/tags/list?n=100&last=bar",
+		`<https://registry.local/v2/foo/tags/list?last=bar&n=100>; rel="next"`:         "/v2/foo/tags/list?last=bar&n=100",
+		`</v2/foo/tags/list?last=a>; rel="prev", </v2/foo/tags/list?last=c>; rel=next`: "/v2/foo/tags/list?last=c",
+		`</v2/foo/tags/list?last=a>; rel="prev"`:                                       "",
+	}
+	for header, expected := range cases {
+		next, err := parseNextLink(header)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", header, err)
+		}
+		if next != expected {
+			t.Fatalf("Expected next link of %q to be %q, got %q", header, expected, next)
+		}
+	}
+}
+
+func TestParseAuthHeader(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected map[string]string
+	}{
+		{
+			header: `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`,
+			expected: map[string]string{
+				"realm":   "https://auth.docker.io/token",
+				"service": "registry.docker.io",
+				"scope":   "repository:library/alpine:pull",
+			},
+		},
+		{
+			header: `Bearer realm="https://auth.example.com/token", scope="repository:foo:pull,push", service="registry"`,
+			expected: map[string]string{
+				"realm":   "https://auth.example.com/token",
+				"scope":   "repository:foo:pull,push",
+				"service": "registry",
+			},
+		},
+		{
+			header: `Bearer realm="https://auth.example.com/token",error="insufficient_scope",scope="repository:a:pull repository:b:pull,push"`,
+			expected: map[string]string{
+				"realm": "https://auth.example.com/token",
+				"error": "insufficient_scope",
+				"scope": "repository:a:pull repository:b:pull,push",
+			},
+		},
+		{
+			header: `Basic realm=Registry`,
+			expected: map[string]string{
+				"realm": "Registry",
+			},
+		},
+		{
+			header:   `Bearer`,
+			expected: map[string]string{},
+		},
+	}
+
+	for _, c := range cases {
+		if opts := parseAuthHeader(c.header); !reflect.DeepEqual(opts, c.expected) {
+			t.Fatalf("Parsing %q: expected %v, got %v", c.header, c.expected, opts)
+		}
+	}
+}
+
+// testRegistry is an in-process stand-in of a registry storing
+// manifests and blobs by repository, optionally with bearer authentication
+type testRegistry struct {
+	*httptest.Server
+	mu        sync.Mutex
+	manifests map[string]testRegistryManifest
+	blobs     map[string][]byte
+	// the repositories of the started uploads
+	pendingUploads map[string]string
+	lastUploadID   int
+	// the number of uploaded and mounted blobs
+	uploads int
+	mounts  int
+	// whether tags can be deleted without deleting their manifest
+	tagDeletion bool
+	// whether requests need a bearer token of their scope, like on the Docker Hub
+	bearerAuth bool
+	// whether listing the tags of a repository is unsupported
+	noTagList bool
+	// the number of GET requests of manifests, which count towards the rate limit of the Docker Hub
+	manifestGets int
+}
+
+type testRegistryManifest struct {
+	mediaType string
+	content   []byte
+}
+
+func newTestRegistry() *testRegistry {
+	r := &testRegistry{
+		manifests:      make(map[string]testRegistryManifest),
+		blobs:          make(map[string][]byte),
+		pendingUploads: make(map[string]string),
+	}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+// registry returns the address of the registry as used in image names
+func (r *testRegistry) registry() string {
+	return strings.TrimPrefix(r.URL, "https://")
+}
+
+// client returns a registry client trusting the certificate of the registry
+func (r *testRegistry) client() *registryClient {
+	c := newRegistryClient(&AuthConfigs{})
+	c.httpClient = r.Client()
+	return c
+}
+
+// putManifest stores the manifest under its digest and the tag, and returns the digest
+func (r *testRegistry) putManifest(repository string, tag string, mediaType string, content string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.storeManifest(repository, tag, mediaType, []byte(content))
+}
+
+func (r *testRegistry) storeManifest(repository string, tag string, mediaType string, content []byte) string {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	manifest := testRegistryManifest{mediaType: mediaType, content: content}
+	r.manifests[repository+"/"+digest] = manifest
+	if tag != "" && tag != digest {
+		r.manifests[repository+"/"+tag] = manifest
+	}
+	return digest
+}
+
+// putBlob stores the blob in the repository and returns its digest
+func (r *testRegistry) putBlob(repository string, content string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+	r.blobs[repository+"/"+digest] = []byte(content)
+	return digest
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		// the token is the scope it is valid for
+		json.NewEncoder(w).Encode(map[string]string{"token": req.URL.Query().Get("scope")})
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	for _, separator := range []string{"/tags/list", "/manifests/", "/blobs/"} {
+		if i := strings.LastIndex(path, separator); i != -1 {
+			if !r.authorize(w, req, path[:i]) {
+				return
+			}
+			break
+		}
+	}
+	if strings.HasSuffix(path, "/tags/list") {
+		if r.noTagList {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.serveTags(w, req, strings.TrimSuffix(path, "/tags/list"))
+		return
+	}
+	if i := strings.LastIndex(path, "/manifests/"); i != -1 {
+		r.serveManifest(w, req, path[:i], path[i+len("/manifests/"):])
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/uploads/"); i != -1 {
+		r.serveUpload(w, req, path[:i], path[i+len("/blobs/uploads/"):])
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i != -1 {
+		blob, ok := r.blobs[path[:i]+"/"+path[i+len("/blobs/"):]]
+		if !ok || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		w.Write(blob)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// authorize checks the bearer token of the request if bearerAuth is set and
+// challenges for the pull or push scope of the repository if it is invalid
+func (r *testRegistry) authorize(w http.ResponseWriter, req *http.Request, repository string) bool {
+	if !r.bearerAuth {
+		return true
+	}
+	scope := "repository:" + repository + ":pull"
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		scope += ",push"
+	}
+	if req.Header.Get("Authorization") == "Bearer "+scope {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="%s"`, r.URL, scope))
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repository string, reference string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		manifest, ok := r.manifests[repository+"/"+reference]
+		if ok {
+			manifest, ok = acceptedTestManifest(manifest, req.Header["Accept"])
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Method == http.MethodGet {
+			r.manifestGets++
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(manifest.content)))
+		w.Write(manifest.content)
+	case http.MethodPut:
+		content, _ := ioutil.ReadAll(req.Body)
+		manifest := &registryManifest{}
+		json.Unmarshal(content, manifest)
+		// the referenced blobs and manifests have to exist
+		for _, descriptor := range manifest.Manifests {
+			if _, ok := r.manifests[repository+"/"+descriptor.Digest]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":[{"code":"MANIFEST_BLOB_UNKNOWN","message":"blob unknown to registry"}]}`)
+				return
+			}
+		}
+		blobs := manifest.Layers
+		if manifest.Config != nil {
+			blobs = append(blobs, *manifest.Config)
+		}
+		for _, descriptor := range blobs {
+			if _, ok := r.blobs[repository+"/"+descriptor.Digest]; !ok && descriptor.MediaType != foreignLayerMediaType {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":[{"code":"MANIFEST_BLOB_UNKNOWN","message":"blob unknown to registry"}]}`)
+				return
+			}
+		}
+		w.Header().Set("Docker-Content-Digest", r.storeManifest(repository, reference, req.Header.Get("Content-Type"), content))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		manifest, ok := r.manifests[repository+"/"+reference]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.HasPrefix(reference, "sha256:") {
+			if !r.tagDeletion {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":[{"code":"UNSUPPORTED","message":"The operation is unsupported."}]}`)
+				return
+			}
+			delete(r.manifests, repository+"/"+reference)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		// deleting a manifest removes all its tags
+		for key, m := range r.manifests {
+			if strings.HasPrefix(key, repository+"/") && bytes.Equal(m.content, manifest.content) {
+				delete(r.manifests, key)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// acceptedTestManifest returns the manifest if its media type is accepted. Like the Docker
+// registry, schema v2 manifests are converted to schema v1 for clients only accepting it,
+// so the digest of the converted manifest differs from the one of the pushed manifest.
+func acceptedTestManifest(manifest testRegistryManifest, accept []string) (testRegistryManifest, bool) {
+	if len(accept) == 0 {
+		return manifest, true
+	}
+	acceptsV1 := false
+	for _, value := range accept {
+		for _, mediaType := range strings.Split(value, ",") {
+			mediaType = strings.TrimSpace(mediaType)
+			if mediaType == manifest.mediaType {
+				return manifest, true
+			}
+			acceptsV1 = acceptsV1 || mediaType == manifestMediaTypeV1Signed
+		}
+	}
+	if acceptsV1 && manifest.mediaType == manifestMediaTypeV2 {
+		return testRegistryManifest{
+			mediaType: manifestMediaTypeV1Signed,
+			content:   []byte(fmt.Sprintf(`{"schemaVersion":1,"converted":"sha256:%x"}`, sha256.Sum256(manifest.content))),
+		}, true
+	}
+	return testRegistryManifest{}, false
+}
+
+// serveUpload starts uploads, mounts blobs of other repositories and completes monolithic uploads
+func (r *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repository string, id string) {
+	switch {
+	case req.Method == http.MethodPost && id == "":
+		query := req.URL.Query()
+		if blob, ok := r.blobs[query.Get("from")+"/"+query.Get("mount")]; ok && query.Get("from") != "" {
+			r.blobs[repository+"/"+query.Get("mount")] = blob
+			r.mounts++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		r.lastUploadID++
+		id = strconv.Itoa(r.lastUploadID)
+		r.pendingUploads[id] = repository
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/uploads/"+id+"?_state=foo")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && r.pendingUploads[id] == repository:
+		content, _ := ioutil.ReadAll(req.Body)
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+		if req.URL.Query().Get("digest") != digest || req.URL.Query().Get("_state") != "foo" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content"}]}`)
+			return
+		}
+		delete(r.pendingUploads, id)
+		r.blobs[repository+"/"+digest] = content
+		r.uploads++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveTags lists the tags of the repository in pages of 'n' tags after 'last'
+func (r *testRegistry) serveTags(w http.ResponseWriter, req *http.Request, repository string) {
+	tags := []string{}
+	for key := range r.manifests {
+		if tag := strings.TrimPrefix(key, repository+"/"); tag != key && !strings.Contains(tag, "/") && !strings.HasPrefix(tag, "sha256:") {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	if last := req.URL.Query().Get("last"); last != "" {
+		tags = tags[sort.SearchStrings(tags, last)+1:]
+	}
+	if n, err := strconv.Atoi(req.URL.Query().Get("n")); err == nil && n < len(tags) {
+		tags = tags[:n]
+		w.Header().Set("Link", fmt.Sprintf(`<%s/v2/%s/tags/list?n=%d&last=%s>; rel="next"`, r.URL, repository, n, tags[n-1]))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": tags})
+}
@@ -0,0 +1,199 @@
+package docker
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+const (
+	// cosignSignatureMediaType is the media type of the layers of a cosign
+	// signature manifest, which contain the signed payload
+	cosignSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// cosignSignatureAnnotation is the layer annotation holding the base64
+	// encoded signature of the payload
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignSignatureType       = "cosign container image signature"
+)
+
+// cosignSignaturePayload is the signed payload of a cosign signature
+type cosignSignaturePayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// imageSignatureVerifySchema returns the schema of the 'verify' block
+// of the resources and data sources verifying image signatures
+func imageSignatureVerifySchema(forceNew bool, conflictsWith ...string) *schema.Schema {
+	return &schema.Schema{
+		Type:          schema.TypeList,
+		Description:   "Verifies the cosign signature of the image against a public key",
+		Optional:      true,
+		ForceNew:      forceNew,
+		MaxItems:      1,
+		ConflictsWith: conflictsWith,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"public_key": {
+					Type:         schema.TypeString,
+					Description:  "The PEM encoded public key the image has to be signed with",
+					Required:     true,
+					ForceNew:     forceNew,
+					ValidateFunc: validateSignaturePublicKey(),
+				},
+			},
+		},
+	}
+}
+
+// getImageSignaturePublicKey returns the public key of the 'verify'
+// block, or an empty string if signatures are not verified
+func getImageSignaturePublicKey(verify []interface{}) string {
+	if len(verify) == 0 || verify[0] == nil {
+		return ""
+	}
+	return verify[0].(map[string]interface{})["public_key"].(string)
+}
+
+// cosignSignatureTag returns the tag cosign stores the signatures
+// of the digest under, e.g. 'sha256-<hex>.sig'
+func cosignSignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// verifyRemoteImageSignature resolves the digest the image name points to on its
+// registry and verifies that it has a valid signature. It returns the verified digest.
+func verifyRemoteImageSignature(ctx context.Context, registryClient *registryClient, imageName string, publicKey string) (string, error) {
+	pullOpts := parseRegistryImageName(imageName)
+	digest := pullOpts.Tag
+	if !strings.Contains(digest, ":") {
+		// the digest of the v2 manifest is signed and pulled, not the one of a converted v1 manifest
+		var err error
+		if digest, err = registryClient.getManifestDigest(ctx, pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, false); err != nil {
+			return "", fmt.Errorf("Unable to resolve the digest of image %s: %s", imageName, err)
+		}
+	}
+	if err := verifyRegistryImageSignature(ctx, registryClient, pullOpts, digest, publicKey); err != nil {
+		return "", fmt.Errorf("Unable to verify the signature of image %s: %s", imageName, err)
+	}
+	return digest, nil
+}
+
+// verifyRegistryImageSignature checks that one of the cosign signatures stored
+// next to the manifest with the digest is valid for the digest and the public key
+func verifyRegistryImageSignature(ctx context.Context, registryClient *registryClient, pullOpts internalPullImageOptions, digest string, publicKey string) error {
+	key, err := parseSignaturePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	manifest, _, _, err := registryClient.getManifest(ctx, pullOpts.Registry, pullOpts.Repository, cosignSignatureTag(digest))
+	if err != nil {
+		return fmt.Errorf("No signature found for %s: %s", digest, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != cosignSignatureMediaType {
+			continue
+		}
+		payload, err := registryClient.getBlob(ctx, pullOpts.Registry, pullOpts.Repository, layer.Digest)
+		if err != nil {
+			return err
+		}
+		if err := verifyCosignSignature(key, payload, layer.Annotations[cosignSignatureAnnotation], digest); err != nil {
+			log.Printf("[DEBUG] Signature %s of %s is not valid: %s", layer.Digest, digest, err)
+			continue
+		}
+		log.Printf("[DEBUG] Verified signature %s of %s", layer.Digest, digest)
+		return nil
+	}
+	return fmt.Errorf("No valid signature found for %s", digest)
+}
+
+// verifyCosignSignature checks the signature of the payload and that
+// the payload is a signature of the manifest with the digest
+func verifyCosignSignature(key crypto.PublicKey, payload []byte, signature string, digest string) error {
+	rawSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Unable to decode signature: %s", err)
+	}
+	if err := verifySignature(key, payload, rawSignature); err != nil {
+		return err
+	}
+
+	var signed cosignSignaturePayload
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return fmt.Errorf("Unable to parse signed payload: %s", err)
+	}
+	if signed.Critical.Type != cosignSignatureType {
+		return fmt.Errorf("Signed payload is of type %q", signed.Critical.Type)
+	}
+	if signed.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("Signed payload is for manifest %s", signed.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// verifySignature checks the signature of the SHA-256 digest of the content
+// for ECDSA and RSA (PKCS #1 v1.5) keys, and of the content for Ed25519 keys
+func verifySignature(key crypto.PublicKey, content []byte, signature []byte) error {
+	hash := sha256.Sum256(content)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		// the signature is the ASN.1 encoded (r, s) pair
+		var ecdsaSignature struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(signature, &ecdsaSignature); err != nil || len(rest) != 0 {
+			return fmt.Errorf("Invalid ECDSA signature encoding")
+		}
+		if !ecdsa.Verify(key, hash[:], ecdsaSignature.R, ecdsaSignature.S) {
+			return fmt.Errorf("Invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("Invalid RSA signature: %s", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, content, signature) {
+			return fmt.Errorf("Invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("Unsupported public key of type %T", key)
+	}
+	return nil
+}
+
+// parseSignaturePublicKey parses a PEM encoded PKIX public key as written by 'cosign generate-key-pair'
+func parseSignaturePublicKey(publicKey string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("Public key is not a PEM encoded 'PUBLIC KEY' block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse public key: %s", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,187 @@
+package docker
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestAccDockerImage_verifyUnsigned(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testAccDockerImageVerifyConfig, "127.0.0.1:15000", "127.0.0.1:15000/tftest-service:v1", testSignaturePublicKey),
+				ExpectError: regexp.MustCompile("No signature found"),
+			},
+		},
+	})
+}
+
+func TestVerifyRemoteImageSignature(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	key, publicKey := generateTestSignatureKey(t)
+	otherKey, _ := generateTestSignatureKey(t)
+
+	signed := registry.putManifest("app", "signed", manifestMediaTypeV2, `{"schemaVersion":2,"layers":[]}`)
+	putTestSignature(t, registry, "app", signed, signed, key)
+	wrongKey := registry.putManifest("app", "wrong-key", manifestMediaTypeV2, `{"schemaVersion":2,"layers":[{}]}`)
+	putTestSignature(t, registry, "app", wrongKey, wrongKey, otherKey)
+	// the signature of another manifest is copied next to this one
+	wrongDigest := registry.putManifest("app", "wrong-digest", manifestMediaTypeV2, `{"schemaVersion":2,"layers":[{},{}]}`)
+	putTestSignature(t, registry, "app", wrongDigest, signed, key)
+	registry.putManifest("app", "unsigned", manifestMediaTypeV2, `{"schemaVersion":2,"config":{}}`)
+	// the registry converts the manifest for clients only accepting schema v1
+	if converted, _ := registry.client().getManifestDigest(context.Background(), registry.registry(), "app", "signed", true); converted == signed {
+		t.Fatalf("Expected the converted manifest to have another digest than %s", signed)
+	}
+
+	cases := []struct {
+		image         string
+		expectedError string
+	}{
+		{image: "app:signed"},
+		{image: "app@" + signed},
+		{image: "app:wrong-key", expectedError: "No valid signature found"},
+		{image: "app:wrong-digest", expectedError: "No valid signature found"},
+		{image: "app:unsigned", expectedError: "No signature found"},
+	}
+
+	for _, c := range cases {
+		digest, err := verifyRemoteImageSignature(context.Background(), registry.client(), registry.registry()+"/"+c.image, publicKey)
+		if c.expectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+				t.Fatalf("Expected error %q for image %s, got %v", c.expectedError, c.image, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error for image %s: %s", c.image, err)
+		}
+		if digest != signed {
+			t.Fatalf("Expected the verified digest %s for image %s, got %s", signed, c.image, digest)
+		}
+	}
+}
+
+func TestDockerRegistryImageVerify(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	key, publicKey := generateTestSignatureKey(t)
+	signed := registry.putManifest("app", "1.0", manifestMediaTypeV2, `{"schemaVersion":2,"layers":[]}`)
+	putTestSignature(t, registry, "app", signed, signed, key)
+	registry.putManifest("app", "2.0", manifestMediaTypeV2, `{"schemaVersion":2,"layers":[{}]}`)
+
+	read := func(tag string) (*schema.ResourceData, error) {
+		d := schema.TestResourceDataRaw(t, dataSourceDockerRegistryImage().Schema, map[string]interface{}{
+			"name":   registry.registry() + "/app:" + tag,
+			"verify": []interface{}{map[string]interface{}{"public_key": publicKey}},
+		})
+		return d, dataSourceDockerRegistryImageRead(d, &ProviderConfig{RegistryClient: registry.client()})
+	}
+
+	d, err := read("1.0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if digest := d.Get("sha256_digest").(string); digest != signed {
+		t.Fatalf("Expected the digest %s, got %s", signed, digest)
+	}
+
+	if _, err := read("2.0"); err == nil || !strings.Contains(err.Error(), "Unable to verify the signature of image") {
+		t.Fatalf("Expected the unsigned image to fail, got %v", err)
+	}
+}
+
+func TestValidateSignaturePublicKey(t *testing.T) {
+	if _, errors := validateSignaturePublicKey()(testSignaturePublicKey, "public_key"); len(errors) != 0 {
+		t.Fatalf("%q should be a valid public key: %q", testSignaturePublicKey, errors)
+	}
+
+	invalidKeys := []string{"", "foo", strings.Replace(testSignaturePublicKey, "PUBLIC KEY", "PRIVATE KEY", -1)}
+	for _, v := range invalidKeys {
+		if _, errors := validateSignaturePublicKey()(v, "public_key"); len(errors) == 0 {
+			t.Fatalf("%q should be an invalid public key", v)
+		}
+	}
+}
+
+// generateTestSignatureKey returns an ECDSA key like 'cosign generate-key-pair'
+// and its PEM encoded public key
+func generateTestSignatureKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// putTestSignature stores a cosign signature of the signed digest
+// in the signature manifest of the digest, like 'cosign sign'
+func putTestSignature(t *testing.T, registry *testRegistry, repository string, digest string, signedDigest string, key crypto.Signer) {
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/%s"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+		registry.registry(), repository, signedDigest)
+	hash := sha256.Sum256([]byte(payload))
+	signature, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadDigest := registry.putBlob(repository, payload)
+	config := registry.putBlob(repository, "{}")
+	registry.putManifest(repository, cosignSignatureTag(digest), manifestMediaTypeOCI, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 2, "digest": "%s"},
+		"layers": [{
+			"mediaType": "application/vnd.dev.cosign.simplesigning.v1+json",
+			"size": %d,
+			"digest": "%s",
+			"annotations": {"dev.cosignproject.cosign/signature": "%s"}
+		}]
+	}`, config, len(payload), payloadDigest, base64.StdEncoding.EncodeToString(signature)))
+}
+
+const testSignaturePublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEEwR0KrkbPqwTspS8DgBRSqPR/A4l
+jSKLVp70gbqlbDzbU9XNPURMoa2nUyUBSK/S8NHtHUBxrEVck1WKbPLAGQ==
+-----END PUBLIC KEY-----
+`
+
+const testAccDockerImageVerifyConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+	}
+}
+resource "docker_image" "foo" {
+	provider = "docker.private"
+	name     = "%s"
+	verify {
+		public_key = <<EOF
+%sEOF
+	}
+}
+`
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"log"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
@@ -13,10 +14,16 @@ func resourceDockerContainer() *schema.Resource {
 		Update:        resourceDockerContainerUpdate,
 		Delete:        resourceDockerContainerDelete,
 		MigrateState:  resourceDockerContainerMigrateState,
+		CustomizeDiff: resourceDockerContainerCustomizeDiff,
 		SchemaVersion: 2,
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
 		StateUpgraders: []schema.StateUpgrader{
 			{
 				Version: 1,
@@ -106,8 +113,12 @@ func resourceDockerContainer() *schema.Resource {
 			"image": {
 				Type:     schema.TypeString,
 				Required: true,
-				ForceNew: true,
-				// DiffSuppressFunc: suppressIfSHAwasAdded(), // TODO mvogel
+				// the container is only replaced if the image ID changes, see resourceDockerContainerCustomizeDiff
+			},
+
+			"image_id": {
+				Type:     schema.TypeString,
+				Computed: true,
 			},
 
 			"hostname": {
@@ -331,9 +342,11 @@ func resourceDockerContainer() *schema.Resource {
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"size_bytes": {
-										Type:        schema.TypeInt,
-										Description: "The size for the tmpfs mount in bytes",
-										Optional:    true,
+										Type:             schema.TypeString,
+										Description:      "The size for the tmpfs mount in bytes or as size like '512m' or '2GiB'",
+										Optional:         true,
+										ValidateFunc:     validateSizeGeqThan(0),
+										DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 									},
 									"mode": {
 										Type:        schema.TypeInt,
@@ -489,6 +502,14 @@ func resourceDockerContainer() *schema.Resource {
 				Set:      schema.HashString,
 			},
 
+			"sensitive_env": {
+				Type:      schema.TypeMap,
+				Optional:  true,
+				ForceNew:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+
 			"links": {
 				Type:       schema.TypeSet,
 				Optional:   true,
@@ -605,23 +626,29 @@ func resourceDockerContainer() *schema.Resource {
 			},
 
 			"memory": {
-				Type:         schema.TypeInt,
-				Optional:     true,
-				ValidateFunc: validateIntegerGeqThan(0),
+				Type:             schema.TypeString,
+				Description:      "The memory limit in MB or as size like '512m' or '2GiB'",
+				Optional:         true,
+				ValidateFunc:     validateSizeGeqThan(0),
+				DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitMegabytes),
 			},
 
 			"memory_swap": {
-				Type:         schema.TypeInt,
-				Optional:     true,
-				ValidateFunc: validateIntegerGeqThan(-1),
+				Type:             schema.TypeString,
+				Description:      "The total memory limit (memory + swap) in MB or as size like '512m' or '2GiB', -1 for unlimited swap",
+				Optional:         true,
+				ValidateFunc:     validateSizeGeqThan(-1),
+				DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitMegabytes),
 			},
 
 			"shm_size": {
-				Type:         schema.TypeInt,
-				Optional:     true,
-				ForceNew:     true,
-				Computed:     true,
-				ValidateFunc: validateIntegerGeqThan(0),
+				Type:             schema.TypeString,
+				Description:      "The size of /dev/shm in MB or as size like '512m' or '2GiB'",
+				Optional:         true,
+				ForceNew:         true,
+				Computed:         true,
+				ValidateFunc:     validateSizeGeqThan(0),
+				DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitMegabytes),
 			},
 
 			"cpu_shares": {
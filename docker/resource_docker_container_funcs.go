@@ -25,19 +25,18 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-units"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
-var (
-	creationTime time.Time
-)
-
 func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) error {
 	var err error
 	client := meta.(*ProviderConfig).DockerClient
 	authConfigs := meta.(*ProviderConfig).AuthConfigs
 	image := d.Get("image").(string)
-	_, err = findImage(image, client, authConfigs)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+	_, err = findImage(ctx, image, client, authConfigs, "")
 	if err != nil {
 		return fmt.Errorf("Unable to create container with image %s: %s", image, err)
 	}
@@ -52,6 +51,14 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 		config.Env = stringSetToStringSlice(v.(*schema.Set))
 	}
 
+	if v, ok := d.GetOk("sensitive_env"); ok {
+		sensitiveEnv := v.(map[string]interface{})
+		if err := validateSensitiveEnv(config.Env, sensitiveEnv); err != nil {
+			return err
+		}
+		config.Env = append(config.Env, mapTypeMapValsToStringSlice(sensitiveEnv)...)
+	}
+
 	if v, ok := d.GetOk("command"); ok {
 		config.Cmd = stringListToStringSlice(v.([]interface{}))
 		for _, v := range config.Cmd {
@@ -194,7 +201,9 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 						for _, rawTmpfsOptions := range value.([]interface{}) {
 							rawTmpfsOptions := rawTmpfsOptions.(map[string]interface{})
 							if value, ok := rawTmpfsOptions["size_bytes"]; ok {
-								mountInstance.TmpfsOptions.SizeBytes = (int64)(value.(int))
+								if mountInstance.TmpfsOptions.SizeBytes, err = parseSize(value.(string), sizeUnitBytes); err != nil {
+									return fmt.Errorf("Unable to parse tmpfs size_bytes of mount %s: %s", mountInstance.Target, err)
+								}
 							}
 							if value, ok := rawTmpfsOptions["mode"]; ok {
 								mountInstance.TmpfsOptions.Mode = os.FileMode(value.(int))
@@ -277,19 +286,21 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 	}
 
 	if v, ok := d.GetOk("memory"); ok {
-		hostConfig.Memory = int64(v.(int)) * 1024 * 1024
+		if hostConfig.Memory, err = parseSize(v.(string), sizeUnitMegabytes); err != nil {
+			return fmt.Errorf("Unable to parse memory: %s", err)
+		}
 	}
 
 	if v, ok := d.GetOk("memory_swap"); ok {
-		swap := int64(v.(int))
-		if swap > 0 {
-			swap = swap * 1024 * 1024
+		if hostConfig.MemorySwap, err = parseSize(v.(string), sizeUnitMegabytes); err != nil {
+			return fmt.Errorf("Unable to parse memory_swap: %s", err)
 		}
-		hostConfig.MemorySwap = swap
 	}
 
 	if v, ok := d.GetOk("shm_size"); ok {
-		hostConfig.ShmSize = int64(v.(int)) * 1024 * 1024
+		if hostConfig.ShmSize, err = parseSize(v.(string), sizeUnitMegabytes); err != nil {
+			return fmt.Errorf("Unable to parse shm_size: %s", err)
+		}
 	}
 
 	if v, ok := d.GetOk("cpu_shares"); ok {
@@ -331,7 +342,7 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 
 	var retContainer container.ContainerCreateCreatedBody
 
-	if retContainer, err = client.ContainerCreate(context.Background(), config, hostConfig, networkingConfig, d.Get("name").(string)); err != nil {
+	if retContainer, err = client.ContainerCreate(ctx, config, hostConfig, networkingConfig, d.Get("name").(string)); err != nil {
 		return fmt.Errorf("Unable to create container: %s", err)
 	}
 
@@ -339,7 +350,7 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 
 	// Still support the deprecated properties
 	if v, ok := d.GetOk("networks"); ok {
-		if err := client.NetworkDisconnect(context.Background(), "bridge", retContainer.ID, false); err != nil {
+		if err := client.NetworkDisconnect(ctx, "bridge", retContainer.ID, false); err != nil {
 			if !strings.Contains(err.Error(), "is not connected to the network bridge") {
 				return fmt.Errorf("Unable to disconnect the default network: %s", err)
 			}
@@ -351,7 +362,7 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 
 		for _, rawNetwork := range v.(*schema.Set).List() {
 			networkID := rawNetwork.(string)
-			if err := client.NetworkConnect(context.Background(), networkID, retContainer.ID, endpointConfig); err != nil {
+			if err := client.NetworkConnect(ctx, networkID, retContainer.ID, endpointConfig); err != nil {
 				return fmt.Errorf("Unable to connect to network '%s': %s", networkID, err)
 			}
 		}
@@ -359,7 +370,7 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 
 	// But overwrite them with the future ones, if set
 	if v, ok := d.GetOk("networks_advanced"); ok {
-		if err := client.NetworkDisconnect(context.Background(), "bridge", retContainer.ID, false); err != nil {
+		if err := client.NetworkDisconnect(ctx, "bridge", retContainer.ID, false); err != nil {
 			if !strings.Contains(err.Error(), "is not connected to the network bridge") {
 				return fmt.Errorf("Unable to disconnect the default network: %s", err)
 			}
@@ -381,7 +392,7 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 			}
 			endpointConfig.IPAMConfig = endpointIPAMConfig
 
-			if err := client.NetworkConnect(context.Background(), networkID, retContainer.ID, endpointConfig); err != nil {
+			if err := client.NetworkConnect(ctx, networkID, retContainer.ID, endpointConfig); err != nil {
 				return fmt.Errorf("Unable to connect to network '%s': %s", networkID, err)
 			}
 		}
@@ -453,16 +464,17 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 			dstPath := "/"
 			uploadContent := bytes.NewReader(buf.Bytes())
 			options := types.CopyToContainerOptions{}
-			if err := client.CopyToContainer(context.Background(), retContainer.ID, dstPath, uploadContent, options); err != nil {
+			if err := client.CopyToContainer(ctx, retContainer.ID, dstPath, uploadContent, options); err != nil {
 				return fmt.Errorf("Unable to upload volume content: %s", err)
 			}
 		}
 	}
 
+	var creationTime time.Time
 	if d.Get("start").(bool) {
 		creationTime = time.Now()
 		options := types.ContainerStartOptions{}
-		if err := client.ContainerStart(context.Background(), retContainer.ID, options); err != nil {
+		if err := client.ContainerStart(ctx, retContainer.ID, options); err != nil {
 			return fmt.Errorf("Unable to start container: %s", err)
 		}
 	}
@@ -470,11 +482,11 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 	if d.Get("attach").(bool) {
 		var b bytes.Buffer
 
-		ctx := context.Background()
-
 		if d.Get("logs").(bool) {
 			go func() {
-				reader, err := client.ContainerLogs(ctx, retContainer.ID, types.ContainerLogsOptions{
+				// the logs are followed until the container exits, which can
+				// happen after this function returned and its context got canceled
+				reader, err := client.ContainerLogs(context.Background(), retContainer.ID, types.ContainerLogsOptions{
 					ShowStdout: true,
 					ShowStderr: true,
 					Follow:     true,
@@ -512,6 +524,20 @@ func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) err
 		}
 	}
 
+	// a container which was not started won't be running until the timeout
+	if d.Get("start").(bool) && d.Get("must_run").(bool) {
+		container, err := waitForContainerRunning(client, retContainer.ID, creationTime, d.Timeout(schema.TimeoutCreate))
+		if err == nil && !container.State.Running {
+			err = fmt.Errorf("Container %s failed to be in running state", retContainer.ID)
+		}
+		if err != nil {
+			// It exited immediately, so error out so dependent containers
+			// aren't started
+			resourceDockerContainerDelete(d, meta)
+			return err
+		}
+	}
+
 	return resourceDockerContainerRead(d, meta)
 }
 
@@ -528,51 +554,18 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 		return nil
 	}
 
-	var container types.ContainerJSON
-
-	// TODO fix this with statefunc
-	loops := 1 // if it hasn't just been created, don't delay
-	if !creationTime.IsZero() {
-		loops = 30 // with 500ms spacing, 15 seconds; ought to be plenty
+	container, err := client.ContainerInspect(context.Background(), apiContainer.ID)
+	if err != nil {
+		return fmt.Errorf("Error inspecting container %s: %s", apiContainer.ID, err)
 	}
-	sleepTime := 500 * time.Millisecond
 
-	for i := loops; i > 0; i-- {
-		container, err = client.ContainerInspect(context.Background(), apiContainer.ID)
-		if err != nil {
-			return fmt.Errorf("Error inspecting container %s: %s", apiContainer.ID, err)
-		}
-
-		jsonObj, _ := json.MarshalIndent(container, "", "\t")
-		log.Printf("[INFO] Docker container inspect: %s", jsonObj)
-
-		if container.State.Running ||
-			!container.State.Running && !d.Get("must_run").(bool) {
-			break
-		}
+	jsonObj, _ := json.MarshalIndent(container, "", "\t")
+	log.Printf("[INFO] Docker container inspect: %s", jsonObj)
 
-		if creationTime.IsZero() { // We didn't just create it, so don't wait around
-			return resourceDockerContainerDelete(d, meta)
-		}
-
-		finishTime, err := time.Parse(time.RFC3339, container.State.FinishedAt)
-		if err != nil {
-			return fmt.Errorf("Container finish time could not be parsed: %s", container.State.FinishedAt)
-		}
-		if finishTime.After(creationTime) {
-			// It exited immediately, so error out so dependent containers
-			// aren't started
-			resourceDockerContainerDelete(d, meta)
-			return fmt.Errorf("Container %s exited after creation, error was: %s", apiContainer.ID, container.State.Error)
-		}
-
-		time.Sleep(sleepTime)
-	}
-
-	// Handle the case of the for loop above running its course
+	// TODO fix this with statefunc
+	// a created container is waited for in create, so a stopped container is removed
 	if !container.State.Running && d.Get("must_run").(bool) {
-		resourceDockerContainerDelete(d, meta)
-		return fmt.Errorf("Container %s failed to be in running state", apiContainer.ID)
+		return resourceDockerContainerDelete(d, meta)
 	}
 
 	if !container.State.Running {
@@ -614,7 +607,12 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 	// logs
 	// "must_run" can't be imported
 	// container_logs
-	d.Set("image", container.Image)
+	d.Set("image_id", container.Image)
+	// the configured image reference is kept, as it resolves to the image ID
+	// and is only set on import
+	if _, ok := d.GetOk("image"); !ok {
+		d.Set("image", container.Config.Image)
+	}
 	d.Set("hostname", container.Config.Hostname)
 	d.Set("domainname", container.Config.Domainname)
 	d.Set("command", container.Config.Cmd)
@@ -646,7 +644,7 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 			},
 		})
 	}
-	d.Set("mounts", getDockerContainerMounts(container))
+	d.Set("mounts", getDockerContainerMounts(container, configuredTmpfsSizes(d.Get("mounts").(*schema.Set))))
 	// volumes
 	d.Set("tmpfs", container.HostConfig.Tmpfs)
 	d.Set("host", container.HostConfig.ExtraHosts)
@@ -666,6 +664,12 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 	// For detail, please see the following URLs.
 	// https://github.com/terraform-providers/terraform-provider-docker/issues/242
 	// https://github.com/terraform-providers/terraform-provider-docker/pull/269
+	// The sensitive environment variables are the exception: only the configured
+	// keys are read back, so a changed value is detected as drift.
+	if v, ok := d.GetOk("sensitive_env"); ok {
+		_, sensitiveEnv := splitSensitiveEnv(mapStringSliceToMap(container.Config.Env), v.(map[string]interface{}))
+		d.Set("sensitive_env", sensitiveEnv)
+	}
 
 	d.Set("links", container.HostConfig.Links)
 	d.Set("privileged", container.HostConfig.Privileged)
@@ -679,13 +683,9 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 	}
 	d.Set("devices", devices)
 	// "destroy_grace_seconds" can't be imported
-	d.Set("memory", container.HostConfig.Memory/1024/1024)
-	if container.HostConfig.MemorySwap > 0 {
-		d.Set("memory_swap", container.HostConfig.MemorySwap/1024/1024)
-	} else {
-		d.Set("memory_swap", container.HostConfig.MemorySwap)
-	}
-	d.Set("shm_size", container.HostConfig.ShmSize/1024/1024)
+	d.Set("memory", flattenSize(d.Get("memory").(string), container.HostConfig.Memory, sizeUnitMegabytes))
+	d.Set("memory_swap", flattenSize(d.Get("memory_swap").(string), container.HostConfig.MemorySwap, sizeUnitMegabytes))
+	d.Set("shm_size", flattenSize(d.Get("shm_size").(string), container.HostConfig.ShmSize, sizeUnitMegabytes))
 	d.Set("cpu_shares", container.HostConfig.CPUShares)
 	d.Set("cpu_set", container.HostConfig.CpusetCpus)
 	d.Set("log_driver", container.HostConfig.LogConfig.Type)
@@ -714,6 +714,62 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
+// resourceDockerContainerCustomizeDiff replaces the container only if the
+// image reference resolves to a different image ID. This way switching
+// between e.g. 'nginx:1.19', its ID and its 'nginx@sha256:' reference
+// does not replace the container, while a constant reference like
+// 'nginx:latest' which was pulled again does.
+func resourceDockerContainerCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if !d.NewValueKnown("image") {
+		return d.ForceNew("image")
+	}
+
+	client := meta.(*ProviderConfig).DockerClient
+	image := d.Get("image").(string)
+	imageID, err := resolveImageID(context.Background(), client, image)
+	if err != nil {
+		return err
+	}
+	oldImageID := d.Get("image_id").(string)
+	if imageID == "" || oldImageID == "" {
+		// a missing image is pulled on the replacement, which is only
+		// planned if the reference changed
+		if d.HasChange("image") {
+			return d.ForceNew("image")
+		}
+		return nil
+	}
+	if imageID == oldImageID {
+		log.Printf("[DEBUG] Image %s resolved to the image %s of the container", image, imageID)
+		return nil
+	}
+
+	log.Printf("[DEBUG] Image %s resolved to '%s' instead of '%s'", image, imageID, oldImageID)
+	if d.HasChange("image") {
+		return d.ForceNew("image")
+	}
+	if err := d.SetNew("image_id", imageID); err != nil {
+		return err
+	}
+	return d.ForceNew("image_id")
+}
+
+// resolveImageID returns the ID of the local image the given reference,
+// e.g. name, ID or digest, points to. It is empty if the image does not exist.
+func resolveImageID(ctx context.Context, dockerClient *client.Client, image string) (string, error) {
+	inspect, _, err := dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("Unable to inspect image %s: %s", image, err)
+	}
+	return inspect.ID, nil
+}
+
 func resourceDockerContainerUpdate(d *schema.ResourceData, meta interface{}) error {
 	attrs := []string{
 		"restart", "max_retry_count", "cpu_shares", "memory", "cpu_set", "memory_swap",
@@ -730,6 +786,11 @@ func resourceDockerContainerUpdate(d *schema.ResourceData, meta interface{}) err
 			// 	ulimits = ulimitsToDockerUlimits(v.(*schema.Set))
 			// }
 
+			memory, err := parseSize(d.Get("memory").(string), sizeUnitMegabytes)
+			if err != nil {
+				return fmt.Errorf("Unable to parse memory: %s", err)
+			}
+
 			updateConfig := container.UpdateConfig{
 				RestartPolicy: container.RestartPolicy{
 					Name:              d.Get("restart").(string),
@@ -737,21 +798,21 @@ func resourceDockerContainerUpdate(d *schema.ResourceData, meta interface{}) err
 				},
 				Resources: container.Resources{
 					CPUShares:  int64(d.Get("cpu_shares").(int)),
-					Memory:     int64(d.Get("memory").(int)) * 1024 * 1024,
+					Memory:     memory,
 					CpusetCpus: d.Get("cpu_set").(string),
 					// Ulimits:    ulimits,
 				},
 			}
 
 			if ms, ok := d.GetOk("memory_swap"); ok {
-				a := int64(ms.(int))
-				if a > 0 {
-					a = a * 1024 * 1024
+				if updateConfig.Resources.MemorySwap, err = parseSize(ms.(string), sizeUnitMegabytes); err != nil {
+					return fmt.Errorf("Unable to parse memory_swap: %s", err)
 				}
-				updateConfig.Resources.MemorySwap = a
 			}
 			client := meta.(*ProviderConfig).DockerClient
-			_, err := client.ContainerUpdate(context.Background(), d.Id(), updateConfig)
+			ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutUpdate))
+			defer cancel()
+			_, err = client.ContainerUpdate(ctx, d.Id(), updateConfig)
 			if err != nil {
 				return fmt.Errorf("Unable to update a container: %w", err)
 			}
@@ -763,6 +824,8 @@ func resourceDockerContainerUpdate(d *schema.ResourceData, meta interface{}) err
 
 func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
 
 	if d.Get("rm").(bool) {
 		d.SetId("")
@@ -774,7 +837,7 @@ func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) err
 		if d.Get("destroy_grace_seconds").(int) > 0 {
 			timeout := time.Duration(int32(d.Get("destroy_grace_seconds").(int))) * time.Second
 
-			if err := client.ContainerStop(context.Background(), d.Id(), &timeout); err != nil {
+			if err := client.ContainerStop(ctx, d.Id(), &timeout); err != nil {
 				return fmt.Errorf("Error stopping container %s: %s", d.Id(), err)
 			}
 		}
@@ -785,11 +848,11 @@ func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) err
 		Force:         true,
 	}
 
-	if err := client.ContainerRemove(context.Background(), d.Id(), removeOpts); err != nil {
+	if err := client.ContainerRemove(ctx, d.Id(), removeOpts); err != nil {
 		return fmt.Errorf("Error deleting container %s: %s", d.Id(), err)
 	}
 
-	waitOkC, errorC := client.ContainerWait(context.Background(), d.Id(), container.WaitConditionRemoved)
+	waitOkC, errorC := client.ContainerWait(ctx, d.Id(), container.WaitConditionRemoved)
 	select {
 	case waitOk := <-waitOkC:
 		log.Printf("[INFO] Container exited with code [%v]: '%s'", waitOk.StatusCode, d.Id())
@@ -803,6 +866,44 @@ func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) err
 	return nil
 }
 
+// waitForContainerRunning waits until the container is running. Its start, die and
+// health_status events trigger the inspection. On timeout the last inspected
+// container is returned without an error.
+func waitForContainerRunning(client *client.Client, containerID string, creationTime time.Time, timeout time.Duration) (types.ContainerJSON, error) {
+	var container types.ContainerJSON
+	ctx := context.Background()
+
+	err := waitForEvents(ctx, client, eventWaitConfig{
+		Filters:      eventFilters("container", containerID, "start", "die", "health_status"),
+		Target:       []string{"running"},
+		Timeout:      timeout,
+		PollInterval: 500 * time.Millisecond,
+	}, func() (bool, error) {
+		var err error
+		container, err = client.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return false, fmt.Errorf("Error inspecting container %s: %s", containerID, err)
+		}
+		if container.State.Running {
+			return true, nil
+		}
+
+		finishTime, err := time.Parse(time.RFC3339, container.State.FinishedAt)
+		if err != nil {
+			return false, fmt.Errorf("Container finish time could not be parsed: %s", container.State.FinishedAt)
+		}
+		if finishTime.After(creationTime) {
+			return false, fmt.Errorf("Container %s exited after creation, error was: %s", containerID, container.State.Error)
+		}
+		return false, nil
+	})
+	if _, ok := err.(*resource.TimeoutError); ok {
+		log.Printf("[WARN] Container %s is not running after %v", containerID, timeout)
+		return container, nil
+	}
+	return container, err
+}
+
 // TODO extract to structures_container.go
 type byPortAndProtocol []string
 
@@ -1049,7 +1150,7 @@ func deviceSetToDockerDevices(devices *schema.Set) []container.DeviceMapping {
 	return retDevices
 }
 
-func getDockerContainerMounts(container types.ContainerJSON) []map[string]interface{} {
+func getDockerContainerMounts(container types.ContainerJSON, tmpfsSizes map[string]string) []map[string]interface{} {
 	mounts := []map[string]interface{}{}
 	for _, mount := range container.HostConfig.Mounts {
 		m := map[string]interface{}{
@@ -1085,7 +1186,7 @@ func getDockerContainerMounts(container types.ContainerJSON) []map[string]interf
 		if mount.TmpfsOptions != nil {
 			m["tmpfs_options"] = []map[string]interface{}{
 				{
-					"size_bytes": mount.TmpfsOptions.SizeBytes,
+					"size_bytes": flattenSize(tmpfsSizes[mount.Target], mount.TmpfsOptions.SizeBytes, sizeUnitBytes),
 					"mode":       mount.TmpfsOptions.Mode,
 				},
 			}
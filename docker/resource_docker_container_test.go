@@ -102,6 +102,46 @@ func TestAccDockerContainer_basic(t *testing.T) {
 	})
 }
 
+func TestAccDockerContainer_imageReference(t *testing.T) {
+	resourceName := "docker_container.foo"
+	var c types.ContainerJSON
+	var containerID string
+
+	testCheckSameContainer := func(*terraform.State) error {
+		if c.ID != containerID {
+			return fmt.Errorf("Container was replaced: expected ID %s, got %s", containerID, c.ID)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerContainerConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning(resourceName, &c),
+					func(*terraform.State) error {
+						containerID = c.ID
+						return nil
+					},
+					resource.TestCheckResourceAttrPair(resourceName, "image_id", "docker_image.foo", "latest"),
+				),
+			},
+			{
+				Config: testAccDockerContainerImageNameConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning(resourceName, &c),
+					testCheckSameContainer,
+					resource.TestCheckResourceAttr(resourceName, "image", "nginx:latest"),
+					resource.TestCheckResourceAttrPair(resourceName, "image_id", "docker_image.foo", "latest"),
+				),
+			},
+		},
+	})
+}
+
 func TestAccDockerContainer_init(t *testing.T) {
 	resourceName := "docker_container.fooinit"
 	var c types.ContainerJSON
@@ -374,6 +414,72 @@ func TestAccDockerContainer_sysctls(t *testing.T) {
 	})
 }
 
+func TestAccDockerContainer_sensitiveEnv(t *testing.T) {
+	var c types.ContainerJSON
+
+	testCheck := func(*terraform.State) error {
+		env := mapStringSliceToMap(c.Config.Env)
+		if env["FOO"] != "bar" {
+			return fmt.Errorf("Bad value for env FOO: expected bar, got %s", env["FOO"])
+		}
+		if env["DB_PASSWORD"] != "secret" {
+			return fmt.Errorf("Bad value for sensitive env DB_PASSWORD: expected secret, got %s", env["DB_PASSWORD"])
+		}
+
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerContainerSensitiveEnvConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning("docker_container.foo", &c),
+					testCheck,
+					resource.TestCheckResourceAttr("docker_container.foo", "sensitive_env.%", "1"),
+					resource.TestCheckResourceAttr("docker_container.foo", "sensitive_env.DB_PASSWORD", "secret"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerContainer_sizes(t *testing.T) {
+	var c types.ContainerJSON
+
+	testCheck := func(*terraform.State) error {
+		if c.HostConfig.Memory != 512*1024*1024 {
+			return fmt.Errorf("Container has wrong memory setting: %d", c.HostConfig.Memory)
+		}
+		if c.HostConfig.MemorySwap != 2*1024*1024*1024 {
+			return fmt.Errorf("Container has wrong memory swap setting: %d", c.HostConfig.MemorySwap)
+		}
+		if c.HostConfig.ShmSize != 128*1024*1024 {
+			return fmt.Errorf("Container has wrong shared memory setting: %d", c.HostConfig.ShmSize)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerContainerSizesConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning("docker_container.foo", &c),
+					testCheck,
+					resource.TestCheckResourceAttr("docker_container.foo", "memory", "512m"),
+					resource.TestCheckResourceAttr("docker_container.foo", "memory_swap", "2GiB"),
+					resource.TestCheckResourceAttr("docker_container.foo", "shm_size", "128m"),
+				),
+			},
+		},
+	})
+}
+
 func TestAccDockerContainer_groupadd_id(t *testing.T) {
 	var c types.ContainerJSON
 
@@ -1740,6 +1846,18 @@ resource "docker_container" "foo" {
 }
 `
 
+const testAccDockerContainerImageNameConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "nginx:latest"
+	depends_on = ["docker_image.foo"]
+}
+`
+
 const testAccDockerContainerInitConfig = `
 resource "docker_image" "fooinit" {
 	name = "nginx:latest"
@@ -2323,6 +2441,36 @@ resource "docker_container" "foo" {
 }
 `
 
+const testAccDockerContainerSensitiveEnvConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "${docker_image.foo.latest}"
+	env = ["FOO=bar"]
+
+	sensitive_env = {
+		DB_PASSWORD = "secret"
+	}
+}
+`
+
+const testAccDockerContainerSizesConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "${docker_image.foo.latest}"
+	memory = "512m"
+	memory_swap = "2GiB"
+	shm_size = "128m"
+}
+`
+
 const testAccDockerContainerGroupAddNameConfig = `
 resource "docker_image" "foo" {
 	name = "nginx:latest"
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"time"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -11,6 +13,14 @@ func resourceDockerImage() *schema.Resource {
 		Update: resourceDockerImageUpdate,
 		Delete: resourceDockerImageDelete,
 
+		CustomizeDiff: resourceDockerImageCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -22,11 +32,46 @@ func resourceDockerImage() *schema.Resource {
 				Computed: true,
 			},
 
+			"platform": {
+				Type:         schema.TypeString,
+				Description:  "The platform of the image to pull or build, e.g. 'linux/arm64'",
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateImagePlatform(),
+			},
+
+			"repo_digest": {
+				Type:        schema.TypeString,
+				Description: "The repo digest of the image, e.g. 'alpine@sha256:...'",
+				Computed:    true,
+			},
+
+			"check_remote_digest": {
+				Type:          schema.TypeBool,
+				Description:   "If true, the image is pulled again if its tag points to another digest on the registry",
+				Optional:      true,
+				ConflictsWith: []string{"build"},
+			},
+
+			"verify": imageSignatureVerifySchema(true, "build"),
+
 			"keep_locally": {
 				Type:     schema.TypeBool,
 				Optional: true,
 			},
 
+			"force_remove": {
+				Type:        schema.TypeBool,
+				Description: "If true, the image is removed on destroy even if stopped containers use it",
+				Optional:    true,
+			},
+
+			"prune_dangling_parents": {
+				Type:        schema.TypeBool,
+				Description: "If true, the untagged parent images are removed with the image on destroy",
+				Optional:    true,
+			},
+
 			"pull_trigger": {
 				Type:          schema.TypeString,
 				Optional:      true,
@@ -51,6 +96,30 @@ func resourceDockerImage() *schema.Resource {
 				},
 			},
 
+			"build_context_hash": {
+				Type:        schema.TypeString,
+				Description: "The hash of the build context. The image is rebuilt if it changes",
+				Computed:    true,
+			},
+
+			"build_log": {
+				Type:        schema.TypeString,
+				Description: "The last lines of output of the last build",
+				Computed:    true,
+			},
+
+			"build_image_id": {
+				Type:        schema.TypeString,
+				Description: "The ID of the image produced by the last build",
+				Computed:    true,
+			},
+
+			"build_args_digest": {
+				Type:        schema.TypeString,
+				Description: "The digest of the build args of the last build",
+				Computed:    true,
+			},
+
 			"build": {
 				Type:          schema.TypeSet,
 				Optional:      true,
@@ -61,7 +130,13 @@ func resourceDockerImage() *schema.Resource {
 						"path": {
 							Type:        schema.TypeString,
 							Description: "Context path",
-							Required:    true,
+							Optional:    true,
+							ForceNew:    true,
+						},
+						"remote_context": {
+							Type:        schema.TypeString,
+							Description: "A Git repository, tarball or Dockerfile URL the daemon fetches the context from",
+							Optional:    true,
 							ForceNew:    true,
 						},
 						"dockerfile": {
@@ -71,6 +146,12 @@ func resourceDockerImage() *schema.Resource {
 							Default:     "Dockerfile",
 							ForceNew:    true,
 						},
+						"dockerfile_inline": {
+							Type:        schema.TypeString,
+							Description: "The content of the Dockerfile, which is added to the context of path or an empty context",
+							Optional:    true,
+							ForceNew:    true,
+						},
 						"tag": {
 							Type:        schema.TypeList,
 							Description: "Name and optionally a tag in the 'name:tag' format",
@@ -117,6 +198,63 @@ func resourceDockerImage() *schema.Resource {
 								Type: schema.TypeString,
 							},
 						},
+						"builder": {
+							Type:         schema.TypeString,
+							Description:  "The builder to use, 'v1' (default) or 'buildkit'",
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validateStringMatchesPattern(`^(v1|buildkit)$`),
+						},
+						"secrets": {
+							Type:        schema.TypeList,
+							Description: "Secrets to expose to the build, requires the buildkit builder",
+							Optional:    true,
+							ForceNew:    true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"id": {
+										Type:        schema.TypeString,
+										Description: "The ID of the secret to mount with 'RUN --mount=type=secret,id=<id>'",
+										Required:    true,
+									},
+									"src": {
+										Type:        schema.TypeString,
+										Description: "The file to read the secret from",
+										Optional:    true,
+									},
+									"value": {
+										Type:        schema.TypeString,
+										Description: "The value of the secret",
+										Optional:    true,
+										Sensitive:   true,
+									},
+								},
+							},
+						},
+						"ssh": {
+							Type:        schema.TypeList,
+							Description: "SSH agent sockets or keys to expose to the build, requires the buildkit builder",
+							Optional:    true,
+							ForceNew:    true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"id": {
+										Type:        schema.TypeString,
+										Description: "The ID to use with 'RUN --mount=type=ssh,id=<id>'",
+										Optional:    true,
+										Default:     "default",
+									},
+									"paths": {
+										Type:        schema.TypeList,
+										Description: "The agent sockets or keys, the agent of SSH_AUTH_SOCK is used if empty",
+										Optional:    true,
+										Elem: &schema.Schema{
+											Type: schema.TypeString,
+										},
+									},
+								},
+							},
+						},
 					},
 				},
 			},
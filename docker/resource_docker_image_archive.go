@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceDockerImageArchive() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerImageArchiveCreate,
+		Read:   resourceDockerImageArchiveRead,
+		Delete: resourceDockerImageArchiveDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"images": {
+				Type:        schema.TypeList,
+				Description: "The names or IDs of the local images to export",
+				Required:    true,
+				ForceNew:    true,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"path": {
+				Type:        schema.TypeString,
+				Description: "The path of the tar file to write",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"image_ids": {
+				Type:        schema.TypeList,
+				Description: "The IDs of the exported images in the order of images",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"sha256": {
+				Type:        schema.TypeString,
+				Description: "The SHA256 hash of the tar file",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceDockerImageArchiveCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	images := stringListToStringSlice(d.Get("images").([]interface{}))
+	imageIDs, err := inspectImageIDs(ctx, client, images)
+	if err != nil {
+		return err
+	}
+
+	path := d.Get("path").(string)
+	log.Printf("[DEBUG] Exporting images %v to %s", images, path)
+	body, err := client.ImageSave(ctx, images)
+	if err != nil {
+		return fmt.Errorf("Unable to export images %v: %s", images, err)
+	}
+	defer body.Close()
+
+	hash, err := writeImageArchive(path, body)
+	if err != nil {
+		return fmt.Errorf("Unable to write image archive %s: %s", path, err)
+	}
+
+	d.SetId(hash)
+	d.Set("image_ids", imageIDs)
+	d.Set("sha256", hash)
+	return nil
+}
+
+func resourceDockerImageArchiveRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+
+	path := d.Get("path").(string)
+	hash, err := hashFile(path)
+	if err != nil {
+		log.Printf("[WARN] Image archive %s is not readable, removing from state: %s", path, err)
+		d.SetId("")
+		return nil
+	}
+	if hash != d.Get("sha256").(string) {
+		log.Printf("[WARN] Image archive %s was modified, removing from state", path)
+		d.SetId("")
+		return nil
+	}
+
+	// the archive is exported again if one of the images moved
+	imageIDs, err := inspectImageIDs(context.Background(), client, stringListToStringSlice(d.Get("images").([]interface{})))
+	if err != nil {
+		log.Printf("[WARN] %s, removing image archive %s from state", err, path)
+		d.SetId("")
+		return nil
+	}
+	for i, imageID := range d.Get("image_ids").([]interface{}) {
+		if imageIDs[i] != imageID.(string) {
+			log.Printf("[WARN] Image %s changed, removing image archive %s from state", d.Get("images").([]interface{})[i], path)
+			d.SetId("")
+			return nil
+		}
+	}
+	return nil
+}
+
+func resourceDockerImageArchiveDelete(d *schema.ResourceData, meta interface{}) error {
+	path := d.Get("path").(string)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove image archive %s: %s", path, err)
+	}
+	d.SetId("")
+	return nil
+}
+
+// inspectImageIDs returns the IDs of the given local images
+func inspectImageIDs(ctx context.Context, client *client.Client, images []string) ([]string, error) {
+	imageIDs := make([]string, len(images))
+	for i, image := range images {
+		inspect, _, err := client.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to inspect image %s: %s", image, err)
+		}
+		imageIDs[i] = inspect.ID
+	}
+	return imageIDs, nil
+}
+
+// writeImageArchive writes the archive to the given path and returns its
+// SHA256 hash. The file is only replaced once the archive is complete.
+func writeImageArchive(path string, archive io.Reader) (string, error) {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmpFile, hasher), archive); err != nil {
+		tmpFile.Close()
+		return "", err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashFile returns the SHA256 hash of the given file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+var sha256Regexp = regexp.MustCompile(`\A[a-f0-9]{64}\z`)
+
+func TestAccDockerImageArchive_basic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tf-test-image-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archivePath := filepath.Join(dir, "alpine.tar")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageArchiveDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageArchiveConfig, archivePath),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image_archive.foo", "sha256", sha256Regexp),
+					resource.TestCheckResourceAttrPair("docker_image_archive.foo", "image_ids.0", "docker_image.foo", "latest"),
+					resource.TestCheckResourceAttrPair("data.docker_image_archive.foo", "sha256", "docker_image_archive.foo", "sha256"),
+					resource.TestCheckResourceAttrPair("data.docker_image_archive.foo", "image_ids.0", "docker_image.foo", "latest"),
+					resource.TestCheckResourceAttr("data.docker_image_archive.foo", "tags.#", "1"),
+					resource.TestCheckResourceAttr("data.docker_image_archive.foo", "tags.0", "alpine:3.11"),
+					resource.TestCheckResourceAttrPair("docker_image_load.foo", "sha256", "docker_image_archive.foo", "sha256"),
+					resource.TestCheckResourceAttr("docker_image_load.foo", "tags.#", "1"),
+					resource.TestCheckResourceAttr("docker_image_load.foo", "tags.0", "alpine:3.11"),
+					resource.TestCheckResourceAttrPair("docker_image_load.foo", "image_ids.0", "docker_image.foo", "latest"),
+					// the image existed before, so it is kept on destroy
+					resource.TestCheckResourceAttr("docker_image_load.foo", "created_tags.#", "0"),
+					resource.TestCheckResourceAttr("docker_image_load.foo", "created_image_ids.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDockerImageArchiveDestroy(s *terraform.State) error {
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "docker_image_archive" {
+			continue
+		}
+
+		if _, err := os.Stat(rs.Primary.Attributes["path"]); !os.IsNotExist(err) {
+			return fmt.Errorf("Image archive still exists: %s", rs.Primary.Attributes["path"])
+		}
+	}
+	return nil
+}
+
+func TestParseImageLoadResponse(t *testing.T) {
+	body := strings.NewReader(`{"stream":"Loaded image: alpine:3.11\n"}
+{"stream":"Loaded image ID: sha256:a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72\n"}
+`)
+	tags, imageIDs, err := parseImageLoadResponse(body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"alpine:3.11"}) {
+		t.Fatalf("Unexpected tags: %v", tags)
+	}
+	if !reflect.DeepEqual(imageIDs, []string{"sha256:a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72"}) {
+		t.Fatalf("Unexpected image IDs: %v", imageIDs)
+	}
+
+	body = strings.NewReader(`{"errorDetail":{"message":"invalid tar header"},"error":"invalid tar header"}`)
+	if _, _, err := parseImageLoadResponse(body); err == nil || err.Error() != "invalid tar header" {
+		t.Fatalf("Expected the error of the daemon, got %v", err)
+	}
+}
+
+func TestImagesCreatedByLoad(t *testing.T) {
+	before := map[string]string{
+		"sha256:1111": "sha256:1111",
+		"app:1.0":     "sha256:1111",
+		"app:latest":  "sha256:1111",
+	}
+	tags := []string{"app:1.0", "app:latest", "app:2.0"}
+	tagImageIDs := []string{"sha256:1111", "sha256:2222", "sha256:2222"}
+	imageIDs := []string{"sha256:1111", "sha256:2222", "sha256:3333"}
+
+	createdTags, createdImageIDs := imagesCreatedByLoad(before, tags, tagImageIDs, imageIDs)
+	// app:latest was moved to the loaded image
+	if !reflect.DeepEqual(createdTags, []string{"app:latest", "app:2.0"}) {
+		t.Fatalf("Unexpected created tags: %v", createdTags)
+	}
+	if !reflect.DeepEqual(createdImageIDs, []string{"sha256:2222", "sha256:3333"}) {
+		t.Fatalf("Unexpected created image IDs: %v", createdImageIDs)
+	}
+}
+
+func TestReadImageArchiveManifest(t *testing.T) {
+	manifest := `[{"Config":"a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72.json","RepoTags":["alpine:3.11","alpine:latest"],"Layers":[]},` +
+		`{"Config":"blobs/sha256/f70734b6a266dcb5f44c383274821207885b549b75c8e119404917a61335981a","RepoTags":null,"Layers":[]}]`
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for name, content := range map[string]string{"repositories": "{}", "manifest.json": manifest} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	imageIDs, tags, err := readImageArchiveManifest(&archive)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expectedImageIDs := []string{
+		"sha256:a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72",
+		"sha256:f70734b6a266dcb5f44c383274821207885b549b75c8e119404917a61335981a",
+	}
+	if !reflect.DeepEqual(imageIDs, expectedImageIDs) {
+		t.Fatalf("Expected image IDs %v, got %v", expectedImageIDs, imageIDs)
+	}
+	if !reflect.DeepEqual(tags, []string{"alpine:3.11", "alpine:latest"}) {
+		t.Fatalf("Unexpected tags: %v", tags)
+	}
+}
+
+const testAccDockerImageArchiveConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.11"
+	keep_locally = true
+}
+
+resource "docker_image_archive" "foo" {
+	images = ["${docker_image.foo.name}"]
+	path = "%s"
+}
+
+data "docker_image_archive" "foo" {
+	path = "${docker_image_archive.foo.path}"
+	depends_on = ["docker_image_archive.foo"]
+}
+
+resource "docker_image_load" "foo" {
+	path = "${docker_image_archive.foo.path}"
+}
+`
@@ -0,0 +1,168 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/filesync"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+)
+
+const (
+	builderBuildKit = "buildkit"
+
+	// buildKitTraceID is the ID of the messages which carry the
+	// BuildKit progress as encoded StatusResponse
+	buildKitTraceID = "moby.buildkit.trace"
+)
+
+// startBuildKitSession opens a BuildKit session which provides the build
+// context, the Dockerfile, the secrets and the forwarded SSH agents to the
+// daemon. It modifies the build options to use the session and returns a
+// func to close the session once the build is done.
+func startBuildKitSession(ctx context.Context, client *client.Client, rawBuild map[string]interface{}, contextDir string, excludes []string, buildOptions *types.ImageBuildOptions) (func(), error) {
+	sess, err := session.NewSession(ctx, filepath.Base(contextDir), "")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create BuildKit session: %s", err)
+	}
+
+	dockerfilePath := filepath.Join(contextDir, buildOptions.Dockerfile)
+	sess.Allow(filesync.NewFSSyncProvider([]filesync.SyncedDir{
+		{
+			Name:     "context",
+			Dir:      contextDir,
+			Excludes: excludes,
+		},
+		{
+			Name: "dockerfile",
+			Dir:  filepath.Dir(dockerfilePath),
+		},
+	}))
+
+	buildSecrets, err := readBuildSecrets(rawBuild["secrets"].([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	sess.Allow(secretsprovider.FromMap(buildSecrets))
+
+	if rawSSH := rawBuild["ssh"].([]interface{}); len(rawSSH) > 0 {
+		sshProvider, err := sshprovider.NewSSHAgentProvider(readBuildSSHAgents(rawSSH))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to forward SSH agent: %s", err)
+		}
+		sess.Allow(sshProvider)
+	}
+
+	buildOptions.Version = types.BuilderBuildKit
+	buildOptions.SessionID = sess.ID()
+	buildOptions.RemoteContext = "client-session"
+	buildOptions.Dockerfile = filepath.Base(dockerfilePath)
+
+	dialer := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+		return client.DialHijack(ctx, "/session", proto, meta)
+	}
+	go func() {
+		if err := sess.Run(ctx, dialer); err != nil {
+			log.Printf("[WARN] BuildKit session %s failed: %s", sess.ID(), err)
+		}
+	}()
+
+	return func() {
+		if err := sess.Close(); err != nil {
+			log.Printf("[WARN] Unable to close BuildKit session %s: %s", sess.ID(), err)
+		}
+	}, nil
+}
+
+// readBuildSecrets reads the secrets of the build by their ID
+// either from the given file or from the value
+func readBuildSecrets(rawSecrets []interface{}) (map[string][]byte, error) {
+	buildSecrets := make(map[string][]byte, len(rawSecrets))
+	for _, rawSecret := range rawSecrets {
+		rawSecret := rawSecret.(map[string]interface{})
+		id := rawSecret["id"].(string)
+		if src := rawSecret["src"].(string); src != "" {
+			data, err := ioutil.ReadFile(src)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read build secret %s: %s", id, err)
+			}
+			buildSecrets[id] = data
+			continue
+		}
+		buildSecrets[id] = []byte(rawSecret["value"].(string))
+	}
+	return buildSecrets, nil
+}
+
+// readBuildSSHAgents reads the SSH agent sockets or keys to forward to the build.
+// Without paths the agent of SSH_AUTH_SOCK is forwarded.
+func readBuildSSHAgents(rawSSH []interface{}) []sshprovider.AgentConfig {
+	agents := make([]sshprovider.AgentConfig, 0, len(rawSSH))
+	for _, rawAgent := range rawSSH {
+		rawAgent := rawAgent.(map[string]interface{})
+		agents = append(agents, sshprovider.AgentConfig{
+			ID:    rawAgent["id"].(string),
+			Paths: stringListToStringSlice(rawAgent["paths"].([]interface{})),
+		})
+	}
+	return agents
+}
+
+// logBuildKitTrace writes the BuildKit progress of a trace message to the debug log
+// and the build output
+func logBuildKitTrace(m jsonmessage.JSONMessage, output *buildOutput) {
+	if m.Aux == nil {
+		return
+	}
+	var data []byte
+	if err := json.Unmarshal(*m.Aux, &data); err != nil {
+		log.Printf("[DEBUG] Unable to decode BuildKit trace: %s", err)
+		return
+	}
+	var status controlapi.StatusResponse
+	if err := status.Unmarshal(data); err != nil {
+		log.Printf("[DEBUG] Unable to decode BuildKit trace: %s", err)
+		return
+	}
+
+	logf := func(format string, a ...interface{}) {
+		line := fmt.Sprintf(format, a...)
+		log.Printf("[DEBUG] %s", line)
+		fmt.Fprintln(&output.log, line)
+	}
+
+	for _, vertex := range status.Vertexes {
+		digest := vertex.Digest.String()
+		switch {
+		case vertex.Error != "":
+			logf("%s ERROR: %s", vertex.Name, vertex.Error)
+			output.step = vertex.Name
+			output.stepOutput = output.vertexOutput[digest]
+		case vertex.Cached:
+			logf("%s CACHED", vertex.Name)
+		case vertex.Completed != nil:
+			logf("%s DONE", vertex.Name)
+		case vertex.Started != nil:
+			logf("%s", vertex.Name)
+		}
+	}
+	for _, vertexLog := range status.Logs {
+		digest := vertexLog.Vertex.String()
+		output.vertexOutput[digest] = appendLastLines(output.vertexOutput[digest], string(vertexLog.Msg))
+		for _, line := range strings.Split(strings.TrimRight(string(vertexLog.Msg), "\n"), "\n") {
+			logf("%s", line)
+		}
+	}
+}
@@ -4,32 +4,198 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"os"
+	"path/filepath"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/docker/cli/cli/command/image/build"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-units"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/mitchellh/go-homedir"
 )
 
-func getBuildContext(filePath string, excludes []string) io.Reader {
-	filePath, _ = homedir.Expand(filePath)
-	ctx, _ := archive.TarWithOptions(filePath, &archive.TarOptions{
+func getBuildContext(filePath string, excludes []string) (io.ReadCloser, error) {
+	filePath, err := homedir.Expand(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// fails e.g. for invalid patterns of the .dockerignore file
+	ctx, err := archive.TarWithOptions(filePath, &archive.TarOptions{
 		ExcludePatterns: excludes,
 	})
-	return ctx
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create the build context of %s: %s", filePath, err)
+	}
+	return ctx, nil
+}
+
+// readBuildContextExcludes reads the patterns of the files to exclude from the
+// build context from the .dockerignore file. The Dockerfile is never excluded.
+func readBuildContextExcludes(contextDir string, dockerfile string) ([]string, error) {
+	excludes, err := build.ReadDockerignore(contextDir)
+	if err != nil {
+		return nil, err
+	}
+	return build.TrimBuildFilesFromExcludes(excludes, dockerfile, false), nil
 }
 
-func decodeBuildMessages(response types.ImageBuildResponse) (string, error) {
-	buf := new(bytes.Buffer)
+// getBuildContextHash computes a hash of the build context which only changes if
+// the content of the context does. The files excluded by the .dockerignore file
+// and the modification times are not taken into account.
+func getBuildContextHash(contextDir string, dockerfile string) (string, error) {
+	contextDir, err := homedir.Expand(contextDir)
+	if err != nil {
+		return "", err
+	}
+	excludes, err := readBuildContextExcludes(contextDir, dockerfile)
+	if err != nil {
+		return "", err
+	}
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	// filepath.Walk visits the files in lexical order so the hash is deterministic
+	err = filepath.Walk(contextDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relFilePath, err := filepath.Rel(contextDir, file)
+		if err != nil || relFilePath == "." {
+			return err
+		}
+
+		excluded, err := pm.Matches(relFilePath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// a directory can only be skipped if no file in it can be re-included
+			if info.IsDir() && !pm.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		fmt.Fprintf(hasher, "%s\x00%o\x00", filepath.ToSlash(relFilePath), info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			io.WriteString(hasher, target)
+		case info.Mode().IsRegular():
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(hasher, f); err != nil {
+				return err
+			}
+		}
+		hasher.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to hash build context %s: %s", contextDir, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// buildErrorOutputLines is the number of output lines
+// of the failing step which are added to a build error
+const buildErrorOutputLines = 20
+
+// buildLogStateLines is the number of lines of the
+// build log which are stored in the state
+const buildLogStateLines = 100
+
+// buildOutput collects the output of an image build
+type buildOutput struct {
+	log             bytes.Buffer
+	imageID         string
+	buildArgsDigest string
+	// the current step of the build and its last lines of output
+	step       string
+	stepOutput []string
+	// the last lines of output of the BuildKit steps by their digest
+	vertexOutput map[string][]string
+}
+
+// logTail returns the last lines of the build log
+func (o *buildOutput) logTail() string {
+	lines := strings.Split(strings.TrimRight(o.log.String(), "\n"), "\n")
+	if len(lines) > buildLogStateLines {
+		lines = lines[len(lines)-buildLogStateLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (o *buildOutput) startStep(step string) {
+	o.step = step
+	o.stepOutput = nil
+}
+
+func (o *buildOutput) addStepOutput(output string) {
+	o.stepOutput = appendLastLines(o.stepOutput, output)
+}
+
+// appendLastLines appends the lines of the output and only keeps the last lines
+func appendLastLines(lines []string, output string) []string {
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		lines = append(lines, line)
+	}
+	if len(lines) > buildErrorOutputLines {
+		lines = lines[len(lines)-buildErrorOutputLines:]
+	}
+	return lines
+}
+
+// buildError is returned if a build fails. It names the failing
+// Dockerfile step and contains its last lines of output.
+type buildError struct {
+	message string
+	step    string
+	output  []string
+}
+
+func (e *buildError) Error() string {
+	msg := fmt.Sprintf("Unable to build image: %s", e.message)
+	if e.step != "" {
+		msg += fmt.Sprintf("\n\nFailing step: %s", e.step)
+	}
+	if len(e.output) > 0 {
+		msg += fmt.Sprintf("\n\nLast %d lines of output:\n%s", len(e.output), strings.Join(e.output, "\n"))
+	}
+	return msg
+}
+
+func decodeBuildMessages(response types.ImageBuildResponse) (*buildOutput, error) {
+	output := &buildOutput{
+		vertexOutput: make(map[string][]string),
+	}
 	buildErr := error(nil)
 
 	dec := json.NewDecoder(response.Body)
@@ -37,61 +203,174 @@ func decodeBuildMessages(response types.ImageBuildResponse) (string, error) {
 		var m jsonmessage.JSONMessage
 		err := dec.Decode(&m)
 		if err != nil {
-			return buf.String(), fmt.Errorf("Problem decoding message from docker daemon: %s", err)
+			// the stream broke off, keep what the build printed so far
+			log.Printf("[DEBUG] %s", output.log.String())
+			return output, &buildError{
+				message: fmt.Sprintf("Problem decoding message from docker daemon: %s", err),
+				step:    output.step,
+				output:  output.stepOutput,
+			}
 		}
 
-		m.Display(buf, false)
+		if m.ID == buildKitTraceID {
+			logBuildKitTrace(m, output)
+			continue
+		}
+
+		if m.Aux != nil {
+			var result types.BuildResult
+			if err := json.Unmarshal(*m.Aux, &result); err == nil && result.ID != "" {
+				output.imageID = result.ID
+			}
+		}
+
+		if m.Stream != "" {
+			if strings.HasPrefix(m.Stream, "Step ") {
+				output.startStep(strings.TrimSpace(m.Stream))
+			} else {
+				output.addStepOutput(m.Stream)
+			}
+		}
+
+		m.Display(&output.log, false)
 
 		if m.Error != nil {
-			buildErr = fmt.Errorf("Unable to build image")
+			buildErr = &buildError{
+				message: m.Error.Message,
+				step:    output.step,
+				output:  output.stepOutput,
+			}
+			fmt.Fprintf(&output.log, "%s\n", m.Error.Message)
 		}
 	}
-	log.Printf("[DEBUG] %s", buf.String())
+	log.Printf("[DEBUG] %s", output.log.String())
+
+	return output, buildErr
+}
+
+// validateBuildContext checks that the build has exactly one context, which is
+// either a local path, a remote context or only the inline Dockerfile
+func validateBuildContext(contextDir string, remoteContext string, dockerfileInline string, builder string) error {
+	switch {
+	case contextDir != "" && remoteContext != "":
+		return fmt.Errorf("Only one of path and remote_context can be set for a build")
+	case contextDir == "" && remoteContext == "" && dockerfileInline == "":
+		return fmt.Errorf("One of path, remote_context or dockerfile_inline is required for a build")
+	case remoteContext != "" && dockerfileInline != "":
+		return fmt.Errorf("dockerfile_inline cannot be used with remote_context")
+	case builder == builderBuildKit && (contextDir == "" || dockerfileInline != ""):
+		return fmt.Errorf("The %s builder requires a path and does not support dockerfile_inline", builderBuildKit)
+	}
+	return nil
+}
+
+// buildArgsDigest returns the digest of the build args
+func buildArgsDigest(buildArgs map[string]*string) string {
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-	return buf.String(), buildErr
+	hasher := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(hasher, "%s=%s\x00", k, *buildArgs[k])
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil))
 }
 
 func resourceDockerImageCreate(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
 	imageName := d.Get("name").(string)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
 	if value, ok := d.GetOk("build"); ok {
 		for _, rawBuild := range value.(*schema.Set).List() {
 			rawBuild := rawBuild.(map[string]interface{})
 
-			err := buildDockerImage(rawBuild, imageName, client)
+			output, err := buildDockerImage(ctx, rawBuild, imageName, d.Get("platform").(string), client)
 			if err != nil {
 				return err
 			}
+			d.Set("build_log", output.logTail())
+			d.Set("build_image_id", output.imageID)
+			d.Set("build_args_digest", output.buildArgsDigest)
+
+			if rawBuild["path"].(string) != "" {
+				contextHash, err := getBuildContextHash(rawBuild["path"].(string), rawBuild["dockerfile"].(string))
+				if err != nil {
+					return err
+				}
+				d.Set("build_context_hash", contextHash)
+			}
+		}
+	}
+	platform := d.Get("platform").(string)
+	verifiedDigest := ""
+	if publicKey := getImageSignaturePublicKey(d.Get("verify").([]interface{})); publicKey != "" {
+		var err error
+		if verifiedDigest, err = verifyRemoteImageSignature(ctx, meta.(*ProviderConfig).RegistryClient, imageName, publicKey); err != nil {
+			return err
+		}
+	}
+	if verifiedDigest != "" && !isImageDigestReference(imageName) {
+		// the tag may move after the verification, so the verified digest is pulled
+		if err := pullVerifiedImage(ctx, client, meta.(*ProviderConfig).AuthConfigs, imageName, verifiedDigest, platform); err != nil {
+			return err
+		}
+	} else if d.Get("check_remote_digest").(bool) && !isImageDigestReference(imageName) {
+		// the tag may have moved on the registry since the local image was pulled
+		var data Data
+		if err := pullImage(ctx, &data, client, meta.(*ProviderConfig).AuthConfigs, imageName, platform); err != nil {
+			return fmt.Errorf("Unable to pull image %s: %s", imageName, err)
 		}
 	}
-	apiImage, err := findImage(imageName, client, meta.(*ProviderConfig).AuthConfigs)
+	apiImage, err := findImage(ctx, imageName, client, meta.(*ProviderConfig).AuthConfigs, platform)
 	if err != nil {
 		return fmt.Errorf("Unable to read Docker image into resource: %s", err)
 	}
+	if verifiedDigest != "" {
+		repoDigest := findRepoDigest(imageRepoDigests(apiImage.RepoDigests, imageName), verifiedDigest)
+		if repoDigest == "" {
+			return fmt.Errorf("Image %s has the repo digests %v instead of the verified digest %s", imageName, apiImage.RepoDigests, verifiedDigest)
+		}
+		d.Set("repo_digest", repoDigest)
+	}
 
-	d.SetId(apiImage.ID + d.Get("name").(string))
+	d.SetId(imageResourceID(apiImage.ID, imageName, platform))
+	d.Set("latest", apiImage.ID)
 	return resourceDockerImageRead(d, meta)
 }
 
 func resourceDockerImageRead(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
 	var data Data
-	if err := fetchLocalImages(&data, client); err != nil {
+	if err := fetchLocalImages(ctx, &data, client); err != nil {
 		return fmt.Errorf("Error reading docker image list: %s", err)
 	}
 	for id := range data.DockerImages {
 		log.Printf("[DEBUG] local images data: %v", id)
 	}
-	foundImage := searchLocalImages(data, d.Get("name").(string))
+	foundImage, err := searchResourceImage(ctx, client, data, d)
+	if err != nil {
+		return err
+	}
 
 	if foundImage == nil {
 		d.SetId("")
 		return nil
 	}
 
-	d.SetId(foundImage.ID + d.Get("name").(string))
+	d.SetId(imageResourceID(foundImage.ID, d.Get("name").(string), d.Get("platform").(string)))
 	d.Set("latest", foundImage.ID)
+	// keep the repo digest matching the registry, which is not necessarily the first one
+	repoDigest := d.Get("repo_digest").(string)
+	if repoDigest == "" || findRepoDigest(imageRepoDigests(foundImage.RepoDigests, d.Get("name").(string)), repoDigest[strings.LastIndex(repoDigest, "@")+1:]) == "" {
+		repoDigest = imageRepoDigest(foundImage, d.Get("name").(string))
+	}
+	d.Set("repo_digest", repoDigest)
 	return nil
 }
 
@@ -100,7 +379,9 @@ func resourceDockerImageUpdate(d *schema.ResourceData, meta interface{}) error {
 	// the value of "latest" or others
 	client := meta.(*ProviderConfig).DockerClient
 	imageName := d.Get("name").(string)
-	apiImage, err := findImage(imageName, client, meta.(*ProviderConfig).AuthConfigs)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutUpdate))
+	defer cancel()
+	apiImage, err := findImage(ctx, imageName, client, meta.(*ProviderConfig).AuthConfigs, d.Get("platform").(string))
 	if err != nil {
 		return fmt.Errorf("Unable to read Docker image into resource: %s", err)
 	}
@@ -110,9 +391,209 @@ func resourceDockerImageUpdate(d *schema.ResourceData, meta interface{}) error {
 	return resourceDockerImageRead(d, meta)
 }
 
+func resourceDockerImageCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffBuildContextHash(d); err != nil {
+		return err
+	}
+	if err := customizeDiffRemoteDigest(d, meta); err != nil {
+		return err
+	}
+	return customizeDiffImageSignature(d, meta)
+}
+
+// customizeDiffBuildContextHash plans a rebuild of the image
+// if the content of the build context changed
+func customizeDiffBuildContextHash(d *schema.ResourceDiff) error {
+	value, ok := d.GetOk("build")
+	if !ok || !d.NewValueKnown("build") {
+		return nil
+	}
+	for _, rawBuild := range value.(*schema.Set).List() {
+		rawBuild := rawBuild.(map[string]interface{})
+		// remote contexts and inline Dockerfiles are only rebuilt on changes of the config
+		if rawBuild["path"].(string) == "" {
+			continue
+		}
+
+		contextHash, err := getBuildContextHash(rawBuild["path"].(string), rawBuild["dockerfile"].(string))
+		if err != nil {
+			return err
+		}
+		oldContextHash := d.Get("build_context_hash").(string)
+		if contextHash == oldContextHash {
+			continue
+		}
+		log.Printf("[DEBUG] Build context hash of image %s changed to %s", d.Get("name").(string), contextHash)
+		if err := d.SetNew("build_context_hash", contextHash); err != nil {
+			return err
+		}
+		// images built before the hash was stored only adopt it
+		if d.Id() != "" && oldContextHash != "" {
+			return d.ForceNew("build_context_hash")
+		}
+	}
+	return nil
+}
+
+// customizeDiffRemoteDigest plans a new pull of the image if
+// its tag points to another manifest on the registry
+func customizeDiffRemoteDigest(d *schema.ResourceDiff, meta interface{}) error {
+	imageName := d.Get("name").(string)
+	if d.Id() == "" || !d.Get("check_remote_digest").(bool) || d.HasChange("name") || isImageDigestReference(imageName) {
+		return nil
+	}
+
+	remoteDigest, err := getRemoteImageDigest(imageName, meta.(*ProviderConfig).RegistryClient)
+	if err != nil {
+		log.Printf("[WARN] Unable to check the remote digest of image %s: %s", imageName, err)
+		return nil
+	}
+	repoDigest := d.Get("repo_digest").(string)
+	if strings.HasSuffix(repoDigest, "@"+remoteDigest) {
+		return nil
+	}
+
+	// the local image may have the remote digest as another repo digest,
+	// e.g. if it was pulled again after the tag moved to the same content
+	client := meta.(*ProviderConfig).DockerClient
+	if image, _, err := client.ImageInspectWithRaw(context.Background(), d.Get("latest").(string)); err == nil {
+		if matching := findRepoDigest(imageRepoDigests(image.RepoDigests, imageName), remoteDigest); matching != "" {
+			log.Printf("[DEBUG] Image %s has the remote digest %s as repo digest", imageName, remoteDigest)
+			return d.SetNew("repo_digest", matching)
+		}
+	}
+
+	log.Printf("[DEBUG] Remote digest of image %s moved from '%s' to %s", imageName, repoDigest, remoteDigest)
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return err
+	}
+	if err := d.SetNew("repo_digest", reference.FamiliarName(named)+"@"+remoteDigest); err != nil {
+		return err
+	}
+	return d.ForceNew("repo_digest")
+}
+
+// pullVerifiedImage pulls the image by its verified digest and tags it with the image name
+func pullVerifiedImage(ctx context.Context, client *client.Client, authConfigs *AuthConfigs, imageName string, digest string, platform string) error {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return err
+	}
+	digestName := reference.FamiliarName(named) + "@" + digest
+
+	var data Data
+	if err := pullImage(ctx, &data, client, authConfigs, digestName, platform); err != nil {
+		return fmt.Errorf("Unable to pull image %s: %s", digestName, err)
+	}
+	log.Printf("[DEBUG] Tagging verified image %s as %s", digestName, imageName)
+	if err := client.ImageTag(ctx, digestName, imageName); err != nil {
+		return fmt.Errorf("Unable to tag image %s as %s: %s", digestName, imageName, err)
+	}
+	return nil
+}
+
+// customizeDiffImageSignature fails the plan if the image to pull, or the
+// pulled image of an existing resource, has no valid signature
+func customizeDiffImageSignature(d *schema.ResourceDiff, meta interface{}) error {
+	publicKey := getImageSignaturePublicKey(d.Get("verify").([]interface{}))
+	if publicKey == "" || !d.NewValueKnown("name") || !d.NewValueKnown("verify") {
+		return nil
+	}
+	registryClient := meta.(*ProviderConfig).RegistryClient
+	imageName := d.Get("name").(string)
+
+	// the repo digest of the pulled image, which Create stores as the verified digest,
+	// or the remote digest the tag moved to if the image is pulled again
+	repoDigest := d.Get("repo_digest").(string)
+	if d.Id() == "" || d.HasChange("name") || !strings.Contains(repoDigest, "@") {
+		_, err := verifyRemoteImageSignature(context.Background(), registryClient, imageName, publicKey)
+		return err
+	}
+
+	digest := repoDigest[strings.LastIndex(repoDigest, "@")+1:]
+	if err := verifyRegistryImageSignature(context.Background(), registryClient, parseRegistryImageName(imageName), digest, publicKey); err != nil {
+		return fmt.Errorf("Unable to verify the signature of image %s: %s", imageName, err)
+	}
+	return nil
+}
+
+// isImageDigestReference checks whether the image name
+// references a digest, e.g. 'alpine@sha256:...'
+func isImageDigestReference(imageName string) bool {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.Canonical)
+	return ok
+}
+
+// imageRepoDigest returns the repo digest of the image for the repository of
+// the image name, e.g. 'alpine@sha256:...' for 'alpine:3.11'. If the name
+// references a digest itself, the matching repo digest is returned.
+func imageRepoDigest(image *types.ImageSummary, imageName string) string {
+	repoDigests := imageRepoDigests(image.RepoDigests, imageName)
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		// e.g. an image ID
+		return ""
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		return findRepoDigest(repoDigests, canonical.Digest().String())
+	}
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	return repoDigests[0]
+}
+
+// imageRepoDigests returns the repo digests of an image for the repository
+// of the image name. A local image has several of them if the tag was
+// pulled again after it moved to a manifest with the same content.
+func imageRepoDigests(repoDigests []string, imageName string) []string {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return nil
+	}
+
+	found := []string{}
+	for _, repoDigest := range repoDigests {
+		repoDigestNamed, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil || repoDigestNamed.Name() != named.Name() {
+			continue
+		}
+		if _, ok := repoDigestNamed.(reference.Canonical); ok {
+			found = append(found, repoDigest)
+		}
+	}
+	return found
+}
+
+// findRepoDigest returns the repo digest which references the digest
+func findRepoDigest(repoDigests []string, digest string) string {
+	for _, repoDigest := range repoDigests {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return repoDigest
+		}
+	}
+	return ""
+}
+
+// verifyImageRepoDigest checks that an image which is referenced
+// by its digest has this digest on the registry
+func verifyImageRepoDigest(image *types.ImageSummary, imageName string) error {
+	if !isImageDigestReference(imageName) || imageRepoDigest(image, imageName) != "" {
+		return nil
+	}
+	return fmt.Errorf("Image %s has the repo digests %v which do not match %s", image.ID, image.RepoDigests, imageName)
+}
+
 func resourceDockerImageDelete(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
-	err := removeImage(d, client)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+	err := removeImage(ctx, d, client)
 	if err != nil {
 		return fmt.Errorf("Unable to remove Docker image: %s", err)
 	}
@@ -120,6 +601,72 @@ func resourceDockerImageDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
+// imageResourceID returns the ID of a docker_image resource. It contains
+// the platform so several platforms of a tag can be managed at once.
+func imageResourceID(imageID string, imageName string, platform string) string {
+	if platform == "" {
+		return imageID + imageName
+	}
+	return imageID + imageName + "/" + platform
+}
+
+// searchResourceImage returns the local image of a docker_image resource.
+// As the tag points to the platform which was pulled last, the image of
+// a platform is searched by its ID first.
+func searchResourceImage(ctx context.Context, client *client.Client, data Data, d *schema.ResourceData) (*types.ImageSummary, error) {
+	platform := d.Get("platform").(string)
+	if platform == "" {
+		return searchLocalImages(data, d.Get("name").(string)), nil
+	}
+
+	foundImage := data.DockerImages[d.Get("latest").(string)]
+	if foundImage == nil {
+		foundImage = searchLocalImages(data, d.Get("name").(string))
+	}
+	if foundImage == nil {
+		return nil, nil
+	}
+	matches, err := imageMatchesPlatform(ctx, client, foundImage.ID, platform)
+	if err != nil || !matches {
+		return nil, err
+	}
+	return foundImage, nil
+}
+
+// imageMatchesPlatform checks whether the local image was built for the given platform
+func imageMatchesPlatform(ctx context.Context, client *client.Client, imageID string, platform string) (bool, error) {
+	inspect, raw, err := client.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		return false, fmt.Errorf("Unable to inspect image %s: %s", imageID, err)
+	}
+	// the variant is not part of the inspect type of the API version in use
+	var variant struct {
+		Variant string
+	}
+	if err := json.Unmarshal(raw, &variant); err != nil {
+		return false, fmt.Errorf("Unable to inspect image %s: %s", imageID, err)
+	}
+	return platformMatches(platform, inspect.Os, inspect.Architecture, variant.Variant)
+}
+
+// platformMatches checks whether the os, architecture and variant of an image
+// match the platform, e.g. 'linux/arm64'. The variant is only compared if
+// both the platform and the image specify it.
+func platformMatches(platform string, os string, arch string, variant string) (bool, error) {
+	expected, err := platforms.Parse(platform)
+	if err != nil {
+		return false, err
+	}
+	actual, err := platforms.Parse(strings.TrimSuffix(os+"/"+arch+"/"+variant, "/"))
+	if err != nil {
+		return false, fmt.Errorf("Unable to parse platform of image: %s", err)
+	}
+	if expected.OS != actual.OS || expected.Architecture != actual.Architecture {
+		return false, nil
+	}
+	return expected.Variant == "" || actual.Variant == "" || expected.Variant == actual.Variant, nil
+}
+
 func searchLocalImages(data Data, imageName string) *types.ImageSummary {
 	if apiImage, ok := data.DockerImages[imageName]; ok {
 		log.Printf("[DEBUG] found local image via imageName: %v", imageName)
@@ -133,14 +680,14 @@ func searchLocalImages(data Data, imageName string) *types.ImageSummary {
 	return nil
 }
 
-func removeImage(d *schema.ResourceData, client *client.Client) error {
+func removeImage(ctx context.Context, d *schema.ResourceData, client *client.Client) error {
 	var data Data
 
 	if keepLocally := d.Get("keep_locally").(bool); keepLocally {
 		return nil
 	}
 
-	if err := fetchLocalImages(&data, client); err != nil {
+	if err := fetchLocalImages(ctx, &data, client); err != nil {
 		return err
 	}
 
@@ -149,21 +696,101 @@ func removeImage(d *schema.ResourceData, client *client.Client) error {
 		return fmt.Errorf("Empty image name is not allowed")
 	}
 
-	foundImage := searchLocalImages(data, imageName)
+	foundImage, err := searchResourceImage(ctx, client, data, d)
+	if err != nil {
+		return err
+	}
 
-	if foundImage != nil {
-		imageDeleteResponseItems, err := client.ImageRemove(context.Background(), foundImage.ID, types.ImageRemoveOptions{})
+	if foundImage == nil {
+		return nil
+	}
+
+	// the image is removed by its name, so the tags of other resources, e.g. docker_tag,
+	// are kept and the image itself is only removed with its last reference
+	removeRef := imageName
+	if searchLocalImages(data, imageName) != foundImage {
+		// the name points to another image by now
+		for _, repoTag := range foundImage.RepoTags {
+			if repoTag != "<none>:<none>" {
+				log.Printf("[INFO] Image %s is kept as it is tagged as %s", foundImage.ID, strings.Join(foundImage.RepoTags, ", "))
+				return nil
+			}
+		}
+		removeRef = foundImage.ID
+	}
+	forceRemove := d.Get("force_remove").(bool)
+	if removeRef == foundImage.ID || imageReferencesKeptByOthers(foundImage.RepoTags, foundImage.RepoDigests, removeRef) == 0 {
+		containers, err := listImageContainers(ctx, client, foundImage.ID)
 		if err != nil {
 			return err
 		}
-		log.Printf("[INFO] Deleted image items: %v", imageDeleteResponseItems)
+		// running containers block the removal even if it is forced
+		if len(containers) > 0 && (!forceRemove || containers[0].State == "running") {
+			return fmt.Errorf("Image %s (%s) is used by the containers %s. "+
+				"Remove the containers, set force_remove to remove the image of stopped containers, "+
+				"or set keep_locally to keep the image.",
+				imageName, foundImage.ID, formatImageContainers(containers))
+		}
+		if len(containers) > 0 {
+			log.Printf("[DEBUG] Forcing removal of image %s used by the stopped containers %s", imageName, formatImageContainers(containers))
+		}
+	}
+
+	imageDeleteResponseItems, err := client.ImageRemove(ctx, removeRef, types.ImageRemoveOptions{
+		Force:         forceRemove,
+		PruneChildren: d.Get("prune_dangling_parents").(bool),
+	})
+	if err != nil {
+		return err
 	}
+	log.Printf("[INFO] Deleted image items: %v", imageDeleteResponseItems)
 
 	return nil
 }
 
-func fetchLocalImages(data *Data, client *client.Client) error {
-	images, err := client.ImageList(context.Background(), types.ImageListOptions{All: false})
+// listImageContainers returns the running and stopped containers which were
+// created from the image, with the running containers first
+func listImageContainers(ctx context.Context, client *client.Client, imageID string) ([]types.Container, error) {
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("ancestor", imageID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list containers of image %s: %s", imageID, err)
+	}
+
+	// the ancestor filter also matches the containers of child images
+	imageContainers := make([]types.Container, 0, len(containers))
+	for _, container := range containers {
+		if container.ImageID == imageID {
+			imageContainers = append(imageContainers, container)
+		}
+	}
+	sort.SliceStable(imageContainers, func(i, j int) bool {
+		return imageContainers[i].State == "running" && imageContainers[j].State != "running"
+	})
+	return imageContainers, nil
+}
+
+// formatImageContainers formats the containers of an image for
+// a message, e.g. 'foo (running), bar (exited)'
+func formatImageContainers(containers []types.Container) string {
+	formatted := make([]string, 0, len(containers))
+	for _, container := range containers {
+		name := container.ID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		formatted = append(formatted, fmt.Sprintf("%s (%s)", name, container.State))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func fetchLocalImages(ctx context.Context, data *Data, client *client.Client) error {
+	images, err := client.ImageList(ctx, types.ImageListOptions{All: false})
 	if err != nil {
 		return fmt.Errorf("Unable to list Docker images: %s", err)
 	}
@@ -189,7 +816,7 @@ func fetchLocalImages(data *Data, client *client.Client) error {
 	return nil
 }
 
-func pullImage(data *Data, client *client.Client, authConfig *AuthConfigs, image string) error {
+func pullImage(ctx context.Context, data *Data, client *client.Client, authConfig *AuthConfigs, image string, platform string) error {
 	pullOpts := parseImageOptions(image)
 
 	// If a registry was specified in the image name, try to find auth for it
@@ -210,20 +837,108 @@ func pullImage(data *Data, client *client.Client, authConfig *AuthConfigs, image
 		return fmt.Errorf("error creating auth config: %s", err)
 	}
 
-	out, err := client.ImagePull(context.Background(), image, types.ImagePullOptions{
+	pullOptions := types.ImagePullOptions{
 		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
-	})
+		Platform:     platform,
+	}
+
+	// the layers which were downloaded completely are kept by the
+	// daemon, so a retry only downloads the interrupted layers
+	for retry := 0; ; retry++ {
+		err = pullImageOnce(ctx, client, image, pullOptions)
+		if err == nil {
+			log.Printf("[DEBUG] pulled image %v", image)
+			return nil
+		}
+		if retry == pullImageRetries || !isInterruptedPullError(err) {
+			return fmt.Errorf("error pulling image %s: %s", image, err)
+		}
+
+		delay := time.Duration(retry+1) * pullImageRetryDelay
+		log.Printf("[WARN] Pull of image %s was interrupted, retrying in %s: %s", image, delay, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error pulling image %s: %s", image, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+const (
+	// pullImageRetries is the number of times an interrupted pull is retried
+	pullImageRetries = 3
+	// pullImageRetryDelay is the delay before the first retry, which
+	// increases with each retry
+	pullImageRetryDelay = 2 * time.Second
+	// pullProgressLogInterval is the minimum interval between
+	// two progress messages of a layer in the debug log
+	pullProgressLogInterval = 10 * time.Second
+)
+
+func pullImageOnce(ctx context.Context, client *client.Client, image string, pullOptions types.ImagePullOptions) error {
+	out, err := client.ImagePull(ctx, image, pullOptions)
 	if err != nil {
-		return fmt.Errorf("error pulling image %s: %s", image, err)
+		return err
 	}
 	defer out.Close()
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(out)
-	s := buf.String()
-	log.Printf("[DEBUG] pulled image %v: %v", image, s)
+	return decodePullMessages(image, out)
+}
 
-	return nil
+// decodePullMessages logs the progress of a pull and returns the error the
+// daemon reports in the message stream. The progress of the layer downloads
+// and extractions is only logged every pullProgressLogInterval.
+func decodePullMessages(image string, body io.Reader) error {
+	lastLogged := make(map[string]time.Time)
+
+	dec := json.NewDecoder(body)
+	for {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("Problem decoding message from docker daemon: %s", err)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+
+		if m.ID == "" {
+			log.Printf("[DEBUG] Pulling image %s: %s", image, m.Status)
+			continue
+		}
+		if m.Progress != nil && m.Progress.Total > 0 {
+			key := m.ID + m.Status
+			if time.Since(lastLogged[key]) < pullProgressLogInterval {
+				continue
+			}
+			lastLogged[key] = time.Now()
+			log.Printf("[DEBUG] Pulling image %s: %s: %s %s/%s", image, m.ID, m.Status,
+				units.HumanSize(float64(m.Progress.Current)), units.HumanSize(float64(m.Progress.Total)))
+			continue
+		}
+		log.Printf("[DEBUG] Pulling image %s: %s: %s", image, m.ID, m.Status)
+	}
+}
+
+// isInterruptedPullError checks whether a pull failed because the
+// connection to the daemon or the registry was interrupted
+func isInterruptedPullError(err error) bool {
+	msg := err.Error()
+	for _, interrupted := range []string{
+		"unexpected EOF",
+		"connection reset by peer",
+		"broken pipe",
+		"i/o timeout",
+		"TLS handshake timeout",
+		"net/http: request canceled",
+	} {
+		if strings.Contains(msg, interrupted) {
+			return true
+		}
+	}
+	return false
 }
 
 type internalPullImageOptions struct {
@@ -263,44 +978,69 @@ func parseImageOptions(image string) internalPullImageOptions {
 	return pullOpts
 }
 
-func findImage(imageName string, client *client.Client, authConfig *AuthConfigs) (*types.ImageSummary, error) {
+// findImage returns the local image or pulls it if it is missing. If a platform
+// is given, the image is pulled again if the local image is of another platform.
+func findImage(ctx context.Context, imageName string, client *client.Client, authConfig *AuthConfigs, platform string) (*types.ImageSummary, error) {
 	if imageName == "" {
 		return nil, fmt.Errorf("Empty image name is not allowed")
 	}
 
 	var data Data
 	// load local images into the data structure
-	if err := fetchLocalImages(&data, client); err != nil {
+	if err := fetchLocalImages(ctx, &data, client); err != nil {
 		return nil, err
 	}
 
 	foundImage := searchLocalImages(data, imageName)
 	if foundImage != nil {
-		return foundImage, nil
+		if platform == "" {
+			return foundImage, nil
+		}
+		matches, err := imageMatchesPlatform(ctx, client, foundImage.ID, platform)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return foundImage, nil
+		}
+		log.Printf("[DEBUG] Local image %s is not of platform %s", imageName, platform)
 	}
 
-	if err := pullImage(&data, client, authConfig, imageName); err != nil {
+	if err := pullImage(ctx, &data, client, authConfig, imageName, platform); err != nil {
 		return nil, fmt.Errorf("Unable to pull image %s: %s", imageName, err)
 	}
 
 	// update the data structure of the images
-	if err := fetchLocalImages(&data, client); err != nil {
+	if err := fetchLocalImages(ctx, &data, client); err != nil {
 		return nil, err
 	}
 
 	foundImage = searchLocalImages(data, imageName)
-	if foundImage != nil {
-		return foundImage, nil
+	if foundImage == nil {
+		return nil, fmt.Errorf("Unable to find or pull image %s", imageName)
 	}
-
-	return nil, fmt.Errorf("Unable to find or pull image %s", imageName)
+	if err := verifyImageRepoDigest(foundImage, imageName); err != nil {
+		return nil, err
+	}
+	if platform != "" {
+		// daemons without multi-platform support ignore the platform
+		matches, err := imageMatchesPlatform(ctx, client, foundImage.ID, platform)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			return nil, fmt.Errorf("Image %s is not available for platform %s", imageName, platform)
+		}
+	}
+	return foundImage, nil
 }
 
-func buildDockerImage(rawBuild map[string]interface{}, imageName string, client *client.Client) error {
+func buildDockerImage(ctx context.Context, rawBuild map[string]interface{}, imageName string, platform string, client *client.Client) (*buildOutput, error) {
 	buildOptions := types.ImageBuildOptions{}
 
 	buildOptions.Version = types.BuilderV1
 	buildOptions.Dockerfile = rawBuild["dockerfile"].(string)
+	buildOptions.Platform = platform
 
 	tags := []string{imageName}
 	for _, t := range rawBuild["tag"].([]interface{}) {
@@ -329,22 +1069,66 @@ func buildDockerImage(rawBuild map[string]interface{}, imageName string, client
 	log.Printf("[DEBUG] Labels: %v\n", labels)
 
 	contextDir := rawBuild["path"].(string)
-	excludes, err := build.ReadDockerignore(contextDir)
-	if err != nil {
-		return err
+	remoteContext := rawBuild["remote_context"].(string)
+	dockerfileInline := rawBuild["dockerfile_inline"].(string)
+	if err := validateBuildContext(contextDir, remoteContext, dockerfileInline, rawBuild["builder"].(string)); err != nil {
+		return nil, err
+	}
+
+	var excludes []string
+	if contextDir != "" {
+		var err error
+		excludes, err = readBuildContextExcludes(contextDir, buildOptions.Dockerfile)
+		if err != nil {
+			return nil, err
+		}
 	}
-	excludes = build.TrimBuildFilesFromExcludes(excludes, buildOptions.Dockerfile, false)
 
-	var response types.ImageBuildResponse
-	response, err = client.ImageBuild(context.Background(), getBuildContext(contextDir, excludes), buildOptions)
+	var buildContext io.Reader
+	if rawBuild["builder"].(string) != builderBuildKit {
+		if len(rawBuild["secrets"].([]interface{})) > 0 || len(rawBuild["ssh"].([]interface{})) > 0 {
+			return nil, fmt.Errorf("Build secrets and ssh require the %s builder", builderBuildKit)
+		}
+		if remoteContext != "" {
+			// the daemon fetches the context itself
+			buildOptions.RemoteContext = remoteContext
+		} else {
+			contextTar := ioutil.NopCloser(&bytes.Buffer{})
+			if contextDir != "" {
+				var err error
+				if contextTar, err = getBuildContext(contextDir, excludes); err != nil {
+					return nil, err
+				}
+			}
+			if dockerfileInline != "" {
+				var err error
+				contextTar, buildOptions.Dockerfile, err = build.AddDockerfileToBuildContext(ioutil.NopCloser(strings.NewReader(dockerfileInline)), contextTar)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to add the inline Dockerfile to the build context: %s", err)
+				}
+			}
+			defer contextTar.Close()
+			buildContext = contextTar
+		}
+	} else {
+		// the context is transferred by the session
+		closeSession, err := startBuildKitSession(ctx, client, rawBuild, contextDir, excludes, &buildOptions)
+		if err != nil {
+			return nil, err
+		}
+		defer closeSession()
+	}
+
+	response, err := client.ImageBuild(ctx, buildContext, buildOptions)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer response.Body.Close()
 
-	buildResult, err := decodeBuildMessages(response)
+	output, err := decodeBuildMessages(response)
 	if err != nil {
-		return fmt.Errorf("%s\n\n%s", err, buildResult)
+		return nil, err
 	}
-	return nil
+	output.buildArgsDigest = buildArgsDigest(buildArgs)
+	return output, nil
 }
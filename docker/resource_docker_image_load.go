@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceDockerImageLoad() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerImageLoadCreate,
+		Read:   resourceDockerImageLoadRead,
+		Update: resourceDockerImageLoadUpdate,
+		Delete: resourceDockerImageLoadDelete,
+
+		CustomizeDiff: resourceDockerImageLoadCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "The path of the tar file to load, as created by 'docker save' or docker_image_archive",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"keep_locally": {
+				Type:        schema.TypeBool,
+				Description: "If true, the loaded images will not be deleted on destroy operation",
+				Optional:    true,
+			},
+
+			"sha256": {
+				Type:        schema.TypeString,
+				Description: "The SHA256 hash of the loaded tar file. The archive is loaded again if it changes",
+				Computed:    true,
+			},
+
+			"image_ids": {
+				Type:        schema.TypeList,
+				Description: "The IDs of the loaded images",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"tags": {
+				Type:        schema.TypeList,
+				Description: "The tags of the loaded images",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"created_tags": {
+				Type:        schema.TypeList,
+				Description: "The tags which did not exist before the archive was loaded. Only these are removed on destroy",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"created_image_ids": {
+				Type:        schema.TypeList,
+				Description: "The IDs of the images which did not exist before the archive was loaded. Only these are removed on destroy",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func resourceDockerImageLoadCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	path := d.Get("path").(string)
+	hash, err := hashFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+	archive, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+	defer archive.Close()
+
+	// only the images and tags created by the load are removed on destroy
+	before, err := listLocalImageReferences(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Loading image archive %s", path)
+	response, err := client.ImageLoad(ctx, archive, true)
+	if err != nil {
+		return fmt.Errorf("Unable to load image archive %s: %s", path, err)
+	}
+	defer response.Body.Close()
+
+	tags, imageIDs, err := parseImageLoadResponse(response.Body)
+	if err != nil {
+		return fmt.Errorf("Unable to load image archive %s: %s", path, err)
+	}
+
+	// the IDs of tagged images are not reported
+	tagImageIDs, err := inspectImageIDs(ctx, client, tags)
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]bool, len(imageIDs))
+	for _, imageID := range imageIDs {
+		loaded[imageID] = true
+	}
+	for _, imageID := range tagImageIDs {
+		if !loaded[imageID] {
+			loaded[imageID] = true
+			imageIDs = append(imageIDs, imageID)
+		}
+	}
+
+	createdTags, createdImageIDs := imagesCreatedByLoad(before, tags, tagImageIDs, imageIDs)
+
+	d.SetId(hash)
+	d.Set("sha256", hash)
+	d.Set("image_ids", imageIDs)
+	d.Set("tags", tags)
+	d.Set("created_tags", createdTags)
+	d.Set("created_image_ids", createdImageIDs)
+	return nil
+}
+
+func resourceDockerImageLoadRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+
+	// the archive is loaded again if one of the images was removed
+	if _, err := inspectImageIDs(context.Background(), client, stringListToStringSlice(d.Get("image_ids").([]interface{}))); err != nil {
+		log.Printf("[WARN] %s, removing loaded image archive %s from state", err, d.Get("path").(string))
+		d.SetId("")
+	}
+	return nil
+}
+
+func resourceDockerImageLoadUpdate(d *schema.ResourceData, meta interface{}) error {
+	// only keep_locally can be updated, which is only used on destroy
+	return resourceDockerImageLoadRead(d, meta)
+}
+
+func resourceDockerImageLoadDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("keep_locally").(bool) {
+		d.SetId("")
+		return nil
+	}
+
+	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	// removing the last tag of an image removes the image as well, the other images
+	// are removed by their ID. Images used by containers are kept and reported.
+	inUse := make([]string, 0)
+	for _, tag := range stringListToStringSlice(d.Get("created_tags").([]interface{})) {
+		removed, err := removeLoadedImage(ctx, client, tag, &inUse)
+		if err != nil {
+			return err
+		}
+		if removed {
+			log.Printf("[DEBUG] Removed loaded tag %s", tag)
+		}
+	}
+	for _, imageID := range stringListToStringSlice(d.Get("created_image_ids").([]interface{})) {
+		removed, err := removeLoadedImage(ctx, client, imageID, &inUse)
+		if err != nil {
+			return err
+		}
+		if removed {
+			log.Printf("[DEBUG] Removed loaded image %s", imageID)
+		}
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("Unable to remove the loaded images %s as they are used by containers. "+
+			"Remove the containers and destroy again, or set keep_locally.", strings.Join(inUse, ", "))
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// removeLoadedImage removes the tag or the untagged image with the ID. An image which
+// is used by containers is kept and added to inUse. Images which are tagged by
+// others since the load are kept as well, as their tags are not removed.
+func removeLoadedImage(ctx context.Context, client *client.Client, image string, inUse *[]string) (bool, error) {
+	inspect, _, err := client.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such image") {
+			return false, nil
+		}
+		return false, fmt.Errorf("Unable to inspect loaded image %s: %s", image, err)
+	}
+
+	isTag := !strings.HasPrefix(image, "sha256:")
+	if !isTag && len(inspect.RepoTags) > 0 {
+		log.Printf("[DEBUG] Keeping loaded image %s, which is tagged as %s", image, strings.Join(inspect.RepoTags, ", "))
+		return false, nil
+	}
+	// removing one of several tags only untags the image
+	if !isTag || len(inspect.RepoTags) <= 1 {
+		containers, err := listImageContainers(ctx, client, inspect.ID)
+		if err != nil {
+			return false, err
+		}
+		if len(containers) > 0 {
+			*inUse = append(*inUse, fmt.Sprintf("%s (used by %s)", image, formatImageContainers(containers)))
+			return false, nil
+		}
+	}
+
+	if _, err := client.ImageRemove(ctx, image, types.ImageRemoveOptions{}); err != nil {
+		if strings.Contains(err.Error(), "No such image") {
+			return false, nil
+		}
+		return false, fmt.Errorf("Unable to remove loaded image %s: %s", image, err)
+	}
+	return true, nil
+}
+
+// listLocalImageReferences returns the IDs of the local images by their tags and IDs
+func listLocalImageReferences(ctx context.Context, client *client.Client) (map[string]string, error) {
+	images, err := client.ImageList(ctx, types.ImageListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list Docker images: %s", err)
+	}
+	references := make(map[string]string)
+	for _, image := range images {
+		references[image.ID] = image.ID
+		for _, tag := range image.RepoTags {
+			references[tag] = image.ID
+		}
+	}
+	return references, nil
+}
+
+// imagesCreatedByLoad returns the loaded tags which did not point to the same image
+// before the load, and the IDs of the loaded images which did not exist before
+func imagesCreatedByLoad(before map[string]string, tags []string, tagImageIDs []string, imageIDs []string) ([]string, []string) {
+	createdTags := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		if before[tag] != tagImageIDs[i] {
+			createdTags = append(createdTags, tag)
+		}
+	}
+	createdImageIDs := make([]string, 0, len(imageIDs))
+	for _, imageID := range imageIDs {
+		if _, ok := before[imageID]; !ok {
+			createdImageIDs = append(createdImageIDs, imageID)
+		}
+	}
+	return createdTags, createdImageIDs
+}
+
+// resourceDockerImageLoadCustomizeDiff loads the archive again if its
+// content changed. A removed archive is ignored, as it is usually
+// only kept until it is loaded.
+func resourceDockerImageLoadCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || d.HasChange("path") {
+		return nil
+	}
+	hash, err := hashFile(d.Get("path").(string))
+	if err != nil {
+		log.Printf("[DEBUG] Image archive %s is not readable: %s", d.Get("path").(string), err)
+		return nil
+	}
+	if hash == d.Get("sha256").(string) {
+		return nil
+	}
+	if err := d.SetNew("sha256", hash); err != nil {
+		return err
+	}
+	return d.ForceNew("sha256")
+}
+
+// parseImageLoadResponse parses the messages of the daemon on loading an image
+// archive and returns the loaded tags and the IDs of the loaded untagged images
+func parseImageLoadResponse(body io.Reader) ([]string, []string, error) {
+	tags := make([]string, 0)
+	imageIDs := make([]string, 0)
+
+	dec := json.NewDecoder(body)
+	for {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, nil, fmt.Errorf("Problem decoding message from docker daemon: %s", err)
+		}
+		if m.Error != nil {
+			return nil, nil, m.Error
+		}
+
+		for _, line := range strings.Split(m.Stream, "\n") {
+			switch {
+			case strings.HasPrefix(line, "Loaded image ID: "):
+				imageIDs = append(imageIDs, strings.TrimPrefix(line, "Loaded image ID: "))
+			case strings.HasPrefix(line, "Loaded image: "):
+				tags = append(tags, strings.TrimPrefix(line, "Loaded image: "))
+			}
+		}
+	}
+	return tags, imageIDs, nil
+}
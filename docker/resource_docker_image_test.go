@@ -7,8 +7,11 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
@@ -188,6 +191,195 @@ func TestAccDockerImage_sha265(t *testing.T) {
 	})
 }
 
+func TestAccDockerImage_platform(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImagePlatformConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.amd64", "latest", contentDigestRegexp),
+					resource.TestMatchResourceAttr("docker_image.arm64", "latest", contentDigestRegexp),
+					resource.TestMatchResourceAttr("docker_image.arm64", "id", regexp.MustCompile(`alpine:3\.11/linux/arm64\z`)),
+					testAccCheckDockerImagePlatform("docker_image.amd64", "amd64"),
+					testAccCheckDockerImagePlatform("docker_image.arm64", "arm64"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckDockerImagePlatform(n string, arch string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+		inspect, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["latest"])
+		if err != nil {
+			return err
+		}
+		if inspect.Architecture != arch {
+			return fmt.Errorf("Image %s is of architecture %s instead of %s", n, inspect.Architecture, arch)
+		}
+		return nil
+	}
+}
+
+func TestAccDockerImage_repoDigest(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImageRepoDigestConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo", "repo_digest", regexp.MustCompile(`\Aalpine@sha256:[a-f0-9]{64}\z`)),
+				),
+			},
+			{
+				// the remote digest is unchanged so no new pull is planned
+				Config:   testAccDockerImageRepoDigestConfig,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_usedByContainer(t *testing.T) {
+	var containerID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImageUsedByContainerConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+					func(s *terraform.State) error {
+						client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+						created, err := client.ContainerCreate(context.Background(), &container.Config{
+							Image: "alpine:3.10",
+						}, nil, nil, "tf-test-image-used-by-container")
+						containerID = created.ID
+						return err
+					},
+				),
+			},
+			{
+				// the image is kept for the container and the destroy names it
+				Config:      testAccDockerImageUsedByContainerConfig,
+				Destroy:     true,
+				ExpectError: regexp.MustCompile("used by the containers tf-test-image-used-by-container \\(created\\)"),
+			},
+			{
+				Config: testAccDockerImageUsedByContainerConfig,
+				PreConfig: func() {
+					client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+					if err := client.ContainerRemove(context.Background(), containerID, types.ContainerRemoveOptions{}); err != nil {
+						t.Fatal(err)
+					}
+				},
+			},
+		},
+	})
+}
+
+func TestFormatImageContainers(t *testing.T) {
+	containers := []types.Container{
+		{ID: "5f1c1e8d6a0b3a0e2c4f", Names: []string{"/foo"}, State: "running"},
+		{ID: "a24bb4013296f8c1e0d2", State: "exited"},
+	}
+	expected := "foo (running), a24bb4013296 (exited)"
+	if formatted := formatImageContainers(containers); formatted != expected {
+		t.Fatalf("Expected %q, got %q", expected, formatted)
+	}
+}
+
+func TestImageRepoDigest(t *testing.T) {
+	image := &types.ImageSummary{
+		ID: "sha256:a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72",
+		RepoDigests: []string{
+			"alpine@sha256:9a839e63dad54c3a6d1834e29692c8492d93f90c59c978c1ed79109ea4fb9a54",
+			"alpine@sha256:ab00606a42621fb68f2ed6ad3c88be54397f981a7b70a79db3d1172b11c4367d",
+			"127.0.0.1:15000/alpine@sha256:39eda93d15866957feaee28f8fc5adb545276a64147445c64992ef69804dbf01",
+		},
+	}
+	cases := []struct {
+		imageName string
+		expected  string
+	}{
+		{"alpine:3.11", "alpine@sha256:9a839e63dad54c3a6d1834e29692c8492d93f90c59c978c1ed79109ea4fb9a54"},
+		{"docker.io/library/alpine", "alpine@sha256:9a839e63dad54c3a6d1834e29692c8492d93f90c59c978c1ed79109ea4fb9a54"},
+		{"alpine@sha256:ab00606a42621fb68f2ed6ad3c88be54397f981a7b70a79db3d1172b11c4367d", "alpine@sha256:ab00606a42621fb68f2ed6ad3c88be54397f981a7b70a79db3d1172b11c4367d"},
+		{"alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000", ""},
+		{"127.0.0.1:15000/alpine:latest", "127.0.0.1:15000/alpine@sha256:39eda93d15866957feaee28f8fc5adb545276a64147445c64992ef69804dbf01"},
+		{"ubuntu:precise", ""},
+		{"sha256:a187dde48cd289ac374ad8539930628314bc581a481cdb41409c9289419ddb72", ""},
+	}
+	for _, c := range cases {
+		if repoDigest := imageRepoDigest(image, c.imageName); repoDigest != c.expected {
+			t.Fatalf("Expected repo digest %q for %s, got %q", c.expected, c.imageName, repoDigest)
+		}
+	}
+
+	repoDigests := imageRepoDigests(image.RepoDigests, "alpine:3.11")
+	if len(repoDigests) != 2 {
+		t.Fatalf("Expected the 2 repo digests of alpine, got %v", repoDigests)
+	}
+	if repoDigest := findRepoDigest(repoDigests, "sha256:ab00606a42621fb68f2ed6ad3c88be54397f981a7b70a79db3d1172b11c4367d"); repoDigest != image.RepoDigests[1] {
+		t.Fatalf("Expected the second repo digest to match the remote digest, got %q", repoDigest)
+	}
+	if repoDigest := findRepoDigest(repoDigests, "sha256:39eda93d15866957feaee28f8fc5adb545276a64147445c64992ef69804dbf01"); repoDigest != "" {
+		t.Fatalf("Expected no repo digest of another repository, got %q", repoDigest)
+	}
+
+	if err := verifyImageRepoDigest(image, "alpine@sha256:ab00606a42621fb68f2ed6ad3c88be54397f981a7b70a79db3d1172b11c4367d"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := verifyImageRepoDigest(image, "alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000"); err == nil {
+		t.Fatal("Expected an error for a digest the image does not have")
+	}
+	if err := verifyImageRepoDigest(image, "ubuntu:precise"); err != nil {
+		t.Fatalf("Expected tags not to be verified: %s", err)
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	cases := []struct {
+		platform string
+		os       string
+		arch     string
+		variant  string
+		expected bool
+	}{
+		{"linux/amd64", "linux", "amd64", "", true},
+		{"linux/arm64", "linux", "amd64", "", false},
+		{"linux/arm64", "linux", "arm64", "v8", true},
+		{"linux/arm64/v8", "linux", "arm64", "", true},
+		{"linux/arm/v7", "linux", "arm", "v7", true},
+		{"linux/arm/v6", "linux", "arm", "v7", false},
+		{"linux/arm", "linux", "arm", "v6", true},
+		{"windows/amd64", "linux", "amd64", "", false},
+	}
+	for _, c := range cases {
+		matches, err := platformMatches(c.platform, c.os, c.arch, c.variant)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", c.platform, err)
+		}
+		if matches != c.expected {
+			t.Fatalf("Expected %s/%s/%s to match %s: %t", c.os, c.arch, c.variant, c.platform, c.expected)
+		}
+	}
+}
+
 func testAccDockerImageDestroy(s *terraform.State) error {
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "docker_image" {
@@ -223,12 +415,293 @@ func TestAccDockerImage_build(t *testing.T) {
 	})
 }
 
+func TestAccDockerImage_buildKit(t *testing.T) {
+	wd, _ := os.Getwd()
+	dfPath := path.Join(wd, "Dockerfile")
+	ioutil.WriteFile(dfPath, []byte(testDockerFileBuildKitExample), 0644)
+	defer os.Remove(dfPath)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testCreateDockerImageBuildKit,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.test", "latest", contentDigestRegexp),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_buildDockerfileInline(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testCreateDockerImageDockerfileInline,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.test", "latest", contentDigestRegexp),
+					resource.TestCheckResourceAttr("docker_image.test", "build_context_hash", ""),
+				),
+			},
+		},
+	})
+}
+
+func TestValidateBuildContext(t *testing.T) {
+	cases := []struct {
+		contextDir       string
+		remoteContext    string
+		dockerfileInline string
+		builder          string
+		valid            bool
+	}{
+		{".", "", "", "", true},
+		{"", "https://github.com/docker-library/hello-world.git", "", "", true},
+		{"", "", "FROM alpine", "", true},
+		{".", "", "FROM alpine", "", true},
+		{".", "", "", builderBuildKit, true},
+		{"", "", "", "", false},
+		{".", "https://github.com/docker-library/hello-world.git", "", "", false},
+		{"", "https://github.com/docker-library/hello-world.git", "FROM alpine", "", false},
+		{"", "", "FROM alpine", builderBuildKit, false},
+		{"", "https://github.com/docker-library/hello-world.git", "", builderBuildKit, false},
+	}
+	for _, c := range cases {
+		err := validateBuildContext(c.contextDir, c.remoteContext, c.dockerfileInline, c.builder)
+		if (err == nil) != c.valid {
+			t.Fatalf("Expected path %q, remote_context %q, dockerfile_inline %q and builder %q to be valid: %t, got %v",
+				c.contextDir, c.remoteContext, c.dockerfileInline, c.builder, c.valid, err)
+		}
+	}
+}
+
+func TestGetBuildContextInvalidExcludes(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "tf-test-build-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	if _, err := getBuildContext(contextDir, []string{"!"}); err == nil {
+		t.Fatal("Expected an error for an invalid exclude pattern")
+	}
+	buildContext, err := getBuildContext(contextDir, []string{"ignored"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	buildContext.Close()
+}
+
+func TestGetBuildContextHash(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "tf-test-build-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	writeFile := func(name, content string) {
+		if err := os.MkdirAll(path.Dir(path.Join(contextDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(contextDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		contextHash, err := getBuildContextHash(contextDir, "Dockerfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contextHash
+	}
+
+	writeFile("Dockerfile", "FROM alpine")
+	writeFile("src/main.go", "package main")
+	writeFile(".dockerignore", "ignored\n")
+	initialHash := hash()
+
+	if hash() != initialHash {
+		t.Fatal("Build context hash is not deterministic")
+	}
+
+	writeFile("ignored/file.txt", "foo")
+	if hash() != initialHash {
+		t.Fatal("Build context hash changed for a file excluded by .dockerignore")
+	}
+
+	writeFile("src/main.go", "package main\n")
+	if hash() == initialHash {
+		t.Fatal("Build context hash did not change for a changed file")
+	}
+}
+
+func TestDecodeBuildMessages(t *testing.T) {
+	body := `{"stream":"Step 1/2 : FROM alpine"}
+{"stream":"\n"}
+{"stream":" ---\u003e a24bb4013296\n"}
+{"stream":"Step 2/2 : RUN echo foo \u0026\u0026 exit 1"}
+{"stream":"\n"}
+{"stream":" ---\u003e Running in 5f1c1e8d6a0b\n"}
+{"stream":"foo\n"}
+{"errorDetail":{"code":1,"message":"The command '/bin/sh -c echo foo \u0026\u0026 exit 1' returned a non-zero code: 1"},"error":"The command '/bin/sh -c echo foo \u0026\u0026 exit 1' returned a non-zero code: 1"}
+`
+	output, err := decodeBuildMessages(types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(body))})
+	buildErr, ok := err.(*buildError)
+	if !ok {
+		t.Fatalf("Expected a build error, got %v", err)
+	}
+	if buildErr.step != "Step 2/2 : RUN echo foo && exit 1" {
+		t.Fatalf("Unexpected failing step: %s", buildErr.step)
+	}
+	if len(buildErr.output) == 0 || buildErr.output[len(buildErr.output)-1] != "foo" {
+		t.Fatalf("Unexpected output of the failing step: %v", buildErr.output)
+	}
+	if !strings.Contains(output.log.String(), "returned a non-zero code: 1") {
+		t.Fatalf("Expected the error in the build log, got: %s", output.log.String())
+	}
+
+	body = `{"stream":"Step 1/1 : FROM alpine"}
+{"stream":"\n"}
+{"aux":{"ID":"sha256:a24bb4013296f61e89ba57005a7b3e52274d8edd3ae2077d04395f806b63d83e"}}
+{"stream":"Successfully built a24bb4013296\n"}
+`
+	output, err = decodeBuildMessages(types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(body))})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if output.imageID != "sha256:a24bb4013296f61e89ba57005a7b3e52274d8edd3ae2077d04395f806b63d83e" {
+		t.Fatalf("Unexpected image ID: %s", output.imageID)
+	}
+
+	// the stream breaks off in the middle of a message
+	body = `{"stream":"Step 1/2 : FROM alpine"}
+{"stream":"\n"}
+{"stream":"Step 2/2 : RUN make"}
+{"stream":"\n"}
+{"stream":"compiling\n"}
+{"stream":"comp`
+	output, err = decodeBuildMessages(types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(body))})
+	buildErr, ok = err.(*buildError)
+	if !ok {
+		t.Fatalf("Expected a build error, got %v", err)
+	}
+	if !strings.Contains(buildErr.message, "Problem decoding message from docker daemon") {
+		t.Fatalf("Unexpected message: %s", buildErr.message)
+	}
+	if buildErr.step != "Step 2/2 : RUN make" {
+		t.Fatalf("Unexpected failing step: %s", buildErr.step)
+	}
+	if len(buildErr.output) == 0 || buildErr.output[len(buildErr.output)-1] != "compiling" {
+		t.Fatalf("Unexpected output of the failing step: %v", buildErr.output)
+	}
+	if !strings.Contains(output.log.String(), "compiling") {
+		t.Fatalf("Expected the partial output in the build log, got: %s", output.log.String())
+	}
+}
+
+func TestBuildOutputLogTail(t *testing.T) {
+	output := &buildOutput{}
+	for i := 1; i <= buildLogStateLines+10; i++ {
+		fmt.Fprintf(&output.log, "line %d\n", i)
+	}
+	lines := strings.Split(output.logTail(), "\n")
+	if len(lines) != buildLogStateLines {
+		t.Fatalf("Expected %d lines, got %d", buildLogStateLines, len(lines))
+	}
+	if lines[0] != "line 11" || lines[len(lines)-1] != fmt.Sprintf("line %d", buildLogStateLines+10) {
+		t.Fatalf("Unexpected log tail: %s ... %s", lines[0], lines[len(lines)-1])
+	}
+}
+
+func TestDecodePullMessages(t *testing.T) {
+	body := `{"status":"Pulling from library/alpine","id":"3.11"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"cbdbe7a5bc2a"}
+{"status":"Downloading","progressDetail":{"current":28672,"total":2813316},"progress":"[>   ]  28.67kB/2.813MB","id":"cbdbe7a5bc2a"}
+{"status":"Downloading","progressDetail":{"current":2813316,"total":2813316},"progress":"[====>]  2.813MB/2.813MB","id":"cbdbe7a5bc2a"}
+{"status":"Pull complete","progressDetail":{},"id":"cbdbe7a5bc2a"}
+{"status":"Digest: sha256:9a839e63dad54c3a6d1834e29692c8492d93f90c59c978c1ed79109ea4fb9a54"}
+{"status":"Status: Downloaded newer image for alpine:3.11"}
+`
+	if err := decodePullMessages("alpine:3.11", strings.NewReader(body)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	body = `{"status":"Pulling from library/alpine","id":"3.11"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"cbdbe7a5bc2a"}
+{"errorDetail":{"message":"write /var/lib/docker/tmp/GetImageBlob123: no space left on device"},"error":"write /var/lib/docker/tmp/GetImageBlob123: no space left on device"}
+`
+	err := decodePullMessages("alpine:3.11", strings.NewReader(body))
+	if err == nil || !strings.Contains(err.Error(), "no space left on device") {
+		t.Fatalf("Expected the error of the daemon, got %v", err)
+	}
+	if isInterruptedPullError(err) {
+		t.Fatalf("Expected %q not to be retried", err)
+	}
+
+	body = `{"status":"Downloading","progressDetail":{"current":28672,"total":2813316},"id":"cbdbe7a5bc2a"}
+{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}
+`
+	err = decodePullMessages("alpine:3.11", strings.NewReader(body))
+	if err == nil || !isInterruptedPullError(err) {
+		t.Fatalf("Expected an interrupted pull, got %v", err)
+	}
+
+	// the stream ends in the middle of a message
+	err = decodePullMessages("alpine:3.11", strings.NewReader(`{"status":"Downloading","progre`))
+	if err == nil || !isInterruptedPullError(err) {
+		t.Fatalf("Expected an interrupted pull, got %v", err)
+	}
+}
+
+func TestBuildArgsDigest(t *testing.T) {
+	foo, bar := "foo", "bar"
+	digest := buildArgsDigest(map[string]*string{"A": &foo, "B": &bar})
+	if digest != buildArgsDigest(map[string]*string{"B": &bar, "A": &foo}) {
+		t.Fatal("Build args digest is not deterministic")
+	}
+	if digest == buildArgsDigest(map[string]*string{"A": &bar, "B": &foo}) {
+		t.Fatal("Build args digest did not change for changed values")
+	}
+}
+
 const testAccDockerImageConfig = `
 resource "docker_image" "foo" {
 	name = "alpine:3.1"
 }
 `
 
+const testAccDockerImageUsedByContainerConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.10"
+	prune_dangling_parents = true
+}
+`
+
+const testAccDockerImageRepoDigestConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.11"
+	check_remote_digest = true
+}
+`
+
+const testAccDockerImagePlatformConfig = `
+resource "docker_image" "amd64" {
+	name = "alpine:3.11"
+	platform = "linux/amd64"
+}
+
+resource "docker_image" "arm64" {
+	name = "alpine:3.11"
+	platform = "linux/arm64"
+	depends_on = ["docker_image.amd64"]
+}
+`
+
 const testAddDockerPrivateImageConfig = `
 resource "docker_image" "foobar" {
 	name = "gcr.io:443/google_containers/pause:0.8.0"
@@ -333,6 +806,18 @@ resource "docker_image" "test" {
   }  
 `
 
+const testCreateDockerImageDockerfileInline = `
+resource "docker_image" "test" {
+	name = "tf-test-dockerfile-inline:latest"
+	build {
+		dockerfile_inline = <<EOF
+FROM alpine:3.12
+RUN echo kenobi > /test_arg.txt
+EOF
+	}
+}
+`
+
 const testDockerFileExample = `
 FROM python:3-stretch
 
@@ -344,3 +829,25 @@ RUN echo ${test_arg} > test_arg.txt
 
 RUN apt-get update -qq
 `
+
+const testCreateDockerImageBuildKit = `
+resource "docker_image" "test" {
+	name = "tf-test-buildkit:latest"
+	build {
+		path = "."
+		dockerfile = "Dockerfile"
+		builder = "buildkit"
+		secrets {
+			id = "test_secret"
+			value = "kenobi"
+		}
+	}
+}
+`
+
+const testDockerFileBuildKitExample = `
+# syntax=docker/dockerfile:1.2
+FROM alpine:3.12
+
+RUN --mount=type=secret,id=test_secret test "$(cat /run/secrets/test_secret)" = "kenobi"
+`
@@ -11,12 +11,13 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/network"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
 func resourceDockerNetworkCreate(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
 	createOpts := types.NetworkCreate{}
 	if v, ok := d.GetOk("labels"); ok {
@@ -60,7 +61,7 @@ func resourceDockerNetworkCreate(d *schema.ResourceData, meta interface{}) error
 	}
 
 	retNetwork := types.NetworkCreateResponse{}
-	retNetwork, err := client.NetworkCreate(context.Background(), d.Get("name").(string), createOpts)
+	retNetwork, err := client.NetworkCreate(ctx, d.Get("name").(string), createOpts)
 	if err != nil {
 		return fmt.Errorf("Unable to create network: %s", err)
 	}
@@ -71,40 +72,36 @@ func resourceDockerNetworkCreate(d *schema.ResourceData, meta interface{}) error
 }
 
 func resourceDockerNetworkRead(d *schema.ResourceData, meta interface{}) error {
-	log.Printf("[INFO] Waiting for network: '%s' to expose all fields: max '%v seconds'", d.Id(), 30)
-
-	stateConf := &resource.StateChangeConf{
-		Pending:    []string{"pending"},
-		Target:     []string{"all_fields", "removed"},
-		Refresh:    resourceDockerNetworkReadRefreshFunc(d, meta),
-		Timeout:    30 * time.Second,
-		MinTimeout: 5 * time.Second,
-		Delay:      2 * time.Second,
-	}
-
-	// Wait, catching any errors
-	_, err := stateConf.WaitForState()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	client := meta.(*ProviderConfig).DockerClient
+	timeout := d.Timeout(schema.TimeoutRead)
+	log.Printf("[INFO] Waiting for network: '%s' to expose all fields: max '%v'", d.Id(), timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return waitForEvents(ctx, client, eventWaitConfig{
+		Filters:      eventFilters("network", d.Id()),
+		Target:       []string{"all_fields", "removed"},
+		Timeout:      timeout,
+		PollInterval: 5 * time.Second,
+		// the fields are exposed without an event of the network
+		Resync: 5 * time.Second,
+	}, resourceDockerNetworkReadRefreshFunc(ctx, d, meta))
 }
 
 func resourceDockerNetworkDelete(d *schema.ResourceData, meta interface{}) error {
-	log.Printf("[INFO] Waiting for network: '%s' to be removed: max '%v seconds'", d.Id(), 30)
-
-	stateConf := &resource.StateChangeConf{
-		Pending:    []string{"pending"},
-		Target:     []string{"removed"},
-		Refresh:    resourceDockerNetworkRemoveRefreshFunc(d, meta),
-		Timeout:    30 * time.Second,
-		MinTimeout: 5 * time.Second,
-		Delay:      2 * time.Second,
-	}
-
-	// Wait, catching any errors
-	_, err := stateConf.WaitForState()
+	client := meta.(*ProviderConfig).DockerClient
+	timeout := d.Timeout(schema.TimeoutDelete)
+	log.Printf("[INFO] Waiting for network: '%s' to be removed: max '%v'", d.Id(), timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the active endpoints are released when the containers disconnect
+	err := waitForEvents(ctx, client, eventWaitConfig{
+		Filters:      eventFilters("network", d.Id(), "disconnect"),
+		Target:       []string{"removed"},
+		Timeout:      timeout,
+		PollInterval: 5 * time.Second,
+	}, resourceDockerNetworkRemoveRefreshFunc(ctx, d, meta))
 	if err != nil {
 		return err
 	}
@@ -136,17 +133,17 @@ func ipamConfigSetToIpamConfigs(ipamConfigSet *schema.Set) []network.IPAMConfig
 	return ipamConfigs
 }
 
-func resourceDockerNetworkReadRefreshFunc(
-	d *schema.ResourceData, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+func resourceDockerNetworkReadRefreshFunc(ctx context.Context,
+	d *schema.ResourceData, meta interface{}) eventWaitCondition {
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
 		networkID := d.Id()
 
-		retNetwork, _, err := client.NetworkInspectWithRaw(context.Background(), networkID, types.NetworkInspectOptions{})
+		retNetwork, _, err := client.NetworkInspectWithRaw(ctx, networkID, types.NetworkInspectOptions{})
 		if err != nil {
 			log.Printf("[WARN] Network (%s) not found, removing from state", networkID)
 			d.SetId("")
-			return networkID, "removed", nil
+			return true, nil
 		}
 
 		jsonObj, _ := json.MarshalIndent(retNetwork, "", "\t")
@@ -166,7 +163,7 @@ func resourceDockerNetworkReadRefreshFunc(
 				d.Set("options", retNetwork.Options)
 			} else {
 				log.Printf("[DEBUG] options: %v not exposed", retNetwork.Options)
-				return networkID, "pending", nil
+				return false, nil
 			}
 		} else {
 			d.Set("options", retNetwork.Options)
@@ -177,30 +174,30 @@ func resourceDockerNetworkReadRefreshFunc(
 		}
 
 		log.Println("[DEBUG] all network fields exposed")
-		return networkID, "all_fields", nil
+		return true, nil
 	}
 }
 
-func resourceDockerNetworkRemoveRefreshFunc(
-	d *schema.ResourceData, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+func resourceDockerNetworkRemoveRefreshFunc(ctx context.Context,
+	d *schema.ResourceData, meta interface{}) eventWaitCondition {
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
 		networkID := d.Id()
 
-		_, _, err := client.NetworkInspectWithRaw(context.Background(), networkID, types.NetworkInspectOptions{})
+		_, _, err := client.NetworkInspectWithRaw(ctx, networkID, types.NetworkInspectOptions{})
 		if err != nil {
 			log.Printf("[INFO] Network (%s) not found. Already removed", networkID)
-			return networkID, "removed", nil
+			return true, nil
 		}
 
-		if err := client.NetworkRemove(context.Background(), networkID); err != nil {
+		if err := client.NetworkRemove(ctx, networkID); err != nil {
 			if strings.Contains(err.Error(), "has active endpoints") {
-				return networkID, "pending", nil
+				return false, nil
 			}
-			return networkID, "other", err
+			return false, err
 		}
 
-		return networkID, "removed", nil
+		return true, nil
 	}
 }
 
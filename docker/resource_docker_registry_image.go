@@ -1,7 +1,7 @@
 package docker
 
 import (
-	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
@@ -13,6 +13,25 @@ func resourceDockerRegistryImage() *schema.Resource {
 		Delete: resourceDockerRegistryImageDelete,
 		Update: resourceDockerRegistryImageUpdate,
 
+		CustomizeDiff: resourceDockerRegistryImageCustomizeDiff,
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceDockerRegistryImageV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: func(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+					return migrateRegistryImageContextHash(rawState), nil
+				},
+			},
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -26,6 +45,13 @@ func resourceDockerRegistryImage() *schema.Resource {
 				Default:  false,
 			},
 
+			"delete_shared_digest": {
+				Type:        schema.TypeBool,
+				Description: "If true, the image is deleted on destroy even if other tags of the repository point to it",
+				Optional:    true,
+				Default:     false,
+			},
+
 			"build": &schema.Schema{
 				Type:     schema.TypeList,
 				Optional: true,
@@ -93,14 +119,18 @@ func resourceDockerRegistryImage() *schema.Resource {
 							ForceNew: true,
 						},
 						"memory": &schema.Schema{
-							Type:     schema.TypeInt,
-							Optional: true,
-							ForceNew: true,
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateFunc:     validateSizeGeqThan(0),
+							DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 						},
 						"memory_swap": &schema.Schema{
-							Type:     schema.TypeInt,
-							Optional: true,
-							ForceNew: true,
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateFunc:     validateSizeGeqThan(-1),
+							DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 						},
 						"cgroup_parent": &schema.Schema{
 							Type:     schema.TypeString,
@@ -113,9 +143,11 @@ func resourceDockerRegistryImage() *schema.Resource {
 							ForceNew: true,
 						},
 						"shm_size": &schema.Schema{
-							Type:     schema.TypeInt,
-							Optional: true,
-							ForceNew: true,
+							Type:             schema.TypeString,
+							Optional:         true,
+							ForceNew:         true,
+							ValidateFunc:     validateSizeGeqThan(0),
+							DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 						},
 						"dockerfile": &schema.Schema{
 							Type:     schema.TypeString,
@@ -198,13 +230,6 @@ func resourceDockerRegistryImage() *schema.Resource {
 							Type:     schema.TypeString,
 							Required: true,
 							ForceNew: true,
-							StateFunc: func(val interface{}) string {
-								// the context hash is stored to identify changes in the context files
-								dockerContextTarPath, _ := buildDockerImageContextTar(val.(string))
-								defer os.Remove(dockerContextTarPath)
-								contextTarHash, _ := getDockerImageContextTarHash(dockerContextTarPath)
-								return val.(string) + ":" + contextTarHash
-							},
 						},
 						"labels": &schema.Schema{
 							Type:     schema.TypeMap,
@@ -258,6 +283,17 @@ func resourceDockerRegistryImage() *schema.Resource {
 							Optional: true,
 							ForceNew: true,
 						},
+						"platforms": &schema.Schema{
+							Type:          schema.TypeList,
+							Description:   "The platforms to build the image for, which are pushed as a manifest list",
+							Optional:      true,
+							ForceNew:      true,
+							ConflictsWith: []string{"build.0.platform"},
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validateImagePlatform(),
+							},
+						},
 						"version": &schema.Schema{
 							Type:     schema.TypeString,
 							Optional: true,
@@ -276,6 +312,18 @@ func resourceDockerRegistryImage() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+
+			"build_context_hash": {
+				Type:        schema.TypeString,
+				Description: "The hash of the build context. The image is built and pushed again if it changes",
+				Computed:    true,
+			},
+
+			"local_image_id": {
+				Type:        schema.TypeString,
+				Description: "The ID of the pushed local image",
+				Computed:    true,
+			},
 		},
 	}
 }
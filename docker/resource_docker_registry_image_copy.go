@@ -0,0 +1,262 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// foreignLayerMediaType is the media type of layers which are not
+// pushed to registries, like the base layers of Windows images
+const foreignLayerMediaType = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+func resourceDockerRegistryImageCopy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerRegistryImageCopyCreate,
+		Read:   resourceDockerRegistryImageCopyRead,
+		Update: resourceDockerRegistryImageCopyUpdate,
+		Delete: resourceDockerRegistryImageCopyDelete,
+
+		CustomizeDiff: resourceDockerRegistryImageCopyCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Type:        schema.TypeString,
+				Description: "The image to copy, e.g. 'staging.local/app:1.2'",
+				Required:    true,
+			},
+
+			"destination": {
+				Type:        schema.TypeString,
+				Description: "The image to copy to, e.g. 'prod.local/app:1.2'",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"keep_remotely": {
+				Type:        schema.TypeBool,
+				Description: "If true, the destination image is not deleted from the registry on destroy",
+				Optional:    true,
+				Default:     false,
+			},
+
+			"delete_shared_digest": {
+				Type:        schema.TypeBool,
+				Description: "If true, the destination image is deleted on destroy even if other tags of its repository point to it",
+				Optional:    true,
+				Default:     false,
+			},
+
+			"source_digest": {
+				Type:        schema.TypeString,
+				Description: "The digest of the copied manifest or manifest list of the source",
+				Computed:    true,
+			},
+
+			"sha256_digest": {
+				Type:        schema.TypeString,
+				Description: "The digest of the destination image",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceDockerRegistryImageCopyCreate(d *schema.ResourceData, meta interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	if err := copyRegistryImageResource(ctx, d, meta); err != nil {
+		return err
+	}
+	d.SetId(d.Get("destination").(string))
+	return nil
+}
+
+func resourceDockerRegistryImageCopyRead(d *schema.ResourceData, meta interface{}) error {
+	destination := d.Get("destination").(string)
+	digest, err := getRemoteImageDigest(destination, meta.(*ProviderConfig).RegistryClient)
+	if err != nil {
+		log.Printf("[WARN] Unable to read digest of image %s, removing from state: %s", destination, err)
+		d.SetId("")
+		return nil
+	}
+	d.Set("sha256_digest", digest)
+	return nil
+}
+
+func resourceDockerRegistryImageCopyUpdate(d *schema.ResourceData, meta interface{}) error {
+	if !d.HasChange("source") && !d.HasChange("source_digest") && !d.HasChange("sha256_digest") {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutUpdate))
+	defer cancel()
+	return copyRegistryImageResource(ctx, d, meta)
+}
+
+func resourceDockerRegistryImageCopyDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("keep_remotely").(bool) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	destination := parseRegistryImageName(d.Get("destination").(string))
+	digest := d.Get("sha256_digest").(string)
+	registryClient := meta.(*ProviderConfig).RegistryClient
+	// e.g. the source if the image was retagged in its repository
+	if !d.Get("delete_shared_digest").(bool) {
+		tagDeleted, err := deleteSharedRegistryImageTag(ctx, registryClient, destination.Registry, destination.Repository, destination.Tag, digest)
+		if err != nil || tagDeleted {
+			return err
+		}
+	}
+
+	log.Printf("[DEBUG] Deleting image %s (%s) from registry", d.Get("destination").(string), digest)
+	if err := registryClient.deleteManifest(ctx, destination.Registry, destination.Repository, digest, false); err != nil {
+		return fmt.Errorf("Unable to delete image %s: %s", d.Get("destination").(string), err)
+	}
+	return nil
+}
+
+// resourceDockerRegistryImageCopyCustomizeDiff copies the image again if the
+// source moved to another manifest or the destination was overwritten
+func resourceDockerRegistryImageCopyCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if d.HasChange("source") {
+		if err := d.SetNewComputed("source_digest"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("sha256_digest")
+	}
+
+	source := d.Get("source").(string)
+	sourceDigest, err := getRemoteImageDigest(source, meta.(*ProviderConfig).RegistryClient)
+	if err != nil {
+		log.Printf("[WARN] Unable to check the digest of image %s: %s", source, err)
+		return nil
+	}
+	if sourceDigest != d.Get("source_digest").(string) {
+		log.Printf("[DEBUG] Source image %s moved from %s to %s", source, d.Get("source_digest").(string), sourceDigest)
+		if err := d.SetNew("source_digest", sourceDigest); err != nil {
+			return err
+		}
+		return d.SetNewComputed("sha256_digest")
+	}
+	if d.Get("sha256_digest").(string) != sourceDigest {
+		log.Printf("[DEBUG] Image %s was overwritten with %s", d.Get("destination").(string), d.Get("sha256_digest").(string))
+		return d.SetNewComputed("sha256_digest")
+	}
+	return nil
+}
+
+func copyRegistryImageResource(ctx context.Context, d *schema.ResourceData, meta interface{}) error {
+	registryClient := meta.(*ProviderConfig).RegistryClient
+	source := d.Get("source").(string)
+	destination := d.Get("destination").(string)
+
+	log.Printf("[DEBUG] Copying image %s to %s", source, destination)
+	sourceDigest, digest, err := copyRegistryImage(ctx, registryClient, parseRegistryImageName(source), parseRegistryImageName(destination))
+	if err != nil {
+		return fmt.Errorf("Unable to copy image %s to %s: %s", source, destination, err)
+	}
+
+	d.Set("source_digest", sourceDigest)
+	d.Set("sha256_digest", digest)
+	return nil
+}
+
+// copyRegistryImage copies the manifest of the source image, or the manifest list with all
+// its manifests, and their blobs to the destination. It returns the digest of the source
+// and the destination manifest, which only differ if the registries change the manifest.
+func copyRegistryImage(ctx context.Context, registryClient *registryClient, source internalPullImageOptions, destination internalPullImageOptions) (string, string, error) {
+	return copyRegistryManifest(ctx, registryClient, source, destination, source.Tag, destination.Tag)
+}
+
+func copyRegistryManifest(ctx context.Context, registryClient *registryClient, source internalPullImageOptions, destination internalPullImageOptions, sourceReference string, destinationReference string) (string, string, error) {
+	manifest, content, mediaType, sourceDigest, err := registryClient.getRawManifest(ctx, source.Registry, source.Repository, sourceReference)
+	if err != nil {
+		return "", "", err
+	}
+
+	if isManifestList(mediaType) {
+		// the manifests of a list are referenced by their digest
+		for _, m := range manifest.Manifests {
+			if _, _, err := copyRegistryManifest(ctx, registryClient, source, destination, m.Digest, m.Digest); err != nil {
+				return "", "", err
+			}
+		}
+	} else {
+		if manifest.SchemaVersion != 2 {
+			return "", "", fmt.Errorf("Manifest %s of version %d is not supported", sourceDigest, manifest.SchemaVersion)
+		}
+		blobs := manifest.Layers
+		if manifest.Config != nil {
+			blobs = append([]registryDescriptor{*manifest.Config}, blobs...)
+		}
+		for _, blob := range blobs {
+			if err := copyRegistryBlob(ctx, registryClient, source, destination, blob); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	log.Printf("[DEBUG] Uploading manifest %s as %s/%s:%s", sourceDigest, destination.Registry, destination.Repository, destinationReference)
+	digest, err := registryClient.putManifest(ctx, destination.Registry, destination.Repository, destinationReference, mediaType, content)
+	if err != nil {
+		return "", "", err
+	}
+	return sourceDigest, digest, nil
+}
+
+// copyRegistryBlob copies the blob unless the destination already contains it. Within
+// the same registry, the blob is mounted from the source repository instead.
+func copyRegistryBlob(ctx context.Context, registryClient *registryClient, source internalPullImageOptions, destination internalPullImageOptions, blob registryDescriptor) error {
+	if blob.MediaType == foreignLayerMediaType {
+		log.Printf("[DEBUG] Skipping foreign layer %s", blob.Digest)
+		return nil
+	}
+
+	exists, err := registryClient.blobExists(ctx, destination.Registry, destination.Repository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Printf("[DEBUG] Blob %s already exists in %s", blob.Digest, destination.Repository)
+		return nil
+	}
+
+	mountFrom := ""
+	if normalizeRegistryAddress(source.Registry) == normalizeRegistryAddress(destination.Registry) {
+		mountFrom = source.Repository
+	}
+	location, err := registryClient.startBlobUpload(ctx, destination.Registry, destination.Repository, blob.Digest, mountFrom)
+	if err != nil {
+		return err
+	}
+	if location == "" {
+		return nil
+	}
+
+	content, err := registryClient.openBlob(ctx, source.Registry, source.Repository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	log.Printf("[DEBUG] Uploading blob %s (%d bytes) to %s", blob.Digest, blob.Size, destination.Repository)
+	return registryClient.uploadBlob(ctx, destination.Registry, destination.Repository, location, blob.Digest, blob.Size, content)
+}
@@ -0,0 +1,185 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccDockerRegistryImageCopy_basic(t *testing.T) {
+	source := "127.0.0.1:15000/tftest-service:v1"
+	destination := createPushImageOptions("127.0.0.1:15000/tftest-copy:v1")
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testDockerRegistryImageNotInRegistry(destination),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerRegistryImageCopyConfig, destination.Registry, source, destination.Name),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_registry_image_copy.foo", "sha256_digest", registryDigestRegexp),
+					resource.TestCheckResourceAttrPair("docker_registry_image_copy.foo", "sha256_digest", "docker_registry_image_copy.foo", "source_digest"),
+					testDockerRegistryImageInRegistry(destination, false),
+				),
+			},
+		},
+	})
+}
+
+func TestCopyRegistryImage(t *testing.T) {
+	staging := newTestRegistry()
+	defer staging.Close()
+	production := newTestRegistry()
+	defer production.Close()
+	// the streamed blob uploads are only authorized by the token of the push scope
+	staging.bearerAuth = true
+	production.bearerAuth = true
+
+	config := staging.putBlob("app", `{"architecture":"amd64","os":"linux"}`)
+	layer := staging.putBlob("app", "layer")
+	image := staging.putManifest("app", "", manifestMediaTypeV2, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": 37, "digest": "%s"},
+		"layers": [
+			{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 5, "digest": "%s"},
+			{"mediaType": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip", "size": 1000, "digest": "sha256:foreign"}
+		]
+	}`, config, layer))
+	list := staging.putManifest("app", "1.0", manifestMediaTypeList, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+		"manifests": [{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 500, "digest": "%s", "platform": {"architecture": "amd64", "os": "linux"}}]
+	}`, image))
+
+	// the client of one test registry trusts the certificate of the other
+	registryClient := staging.client()
+	source := parseRegistryImageName(staging.registry() + "/app:1.0")
+
+	// between registries the blobs are uploaded
+	sourceDigest, digest, err := copyRegistryImage(context.Background(), registryClient, source, parseRegistryImageName(production.registry()+"/app:1.0"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if sourceDigest != list || digest != list {
+		t.Fatalf("Expected digests %s, got %s and %s", list, sourceDigest, digest)
+	}
+	if production.uploads != 2 || production.mounts != 0 {
+		t.Fatalf("Expected 2 uploaded blobs, got %d uploads and %d mounts", production.uploads, production.mounts)
+	}
+	if _, ok := production.manifests["app/1.0"]; !ok {
+		t.Fatalf("Manifest list was not copied")
+	}
+	if _, ok := production.manifests["app/"+image]; !ok {
+		t.Fatalf("Manifest %s was not copied", image)
+	}
+
+	// copying again does not upload existing blobs
+	if _, _, err := copyRegistryImage(context.Background(), registryClient, source, parseRegistryImageName(production.registry()+"/app:latest")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if production.uploads != 2 {
+		t.Fatalf("Expected no further uploads, got %d uploads", production.uploads)
+	}
+
+	// within a registry the blobs are mounted
+	if _, _, err := copyRegistryImage(context.Background(), registryClient, source, parseRegistryImageName(staging.registry()+"/prod/app:1.0")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if staging.mounts != 2 || staging.uploads != 0 {
+		t.Fatalf("Expected 2 mounted blobs, got %d mounts and %d uploads", staging.mounts, staging.uploads)
+	}
+	if _, ok := staging.manifests["prod/app/1.0"]; !ok {
+		t.Fatalf("Manifest list was not copied")
+	}
+}
+
+func TestDockerRegistryImageCopyDeleteSharedDigest(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+	registry.tagDeletion = true
+
+	// the image was retagged within its repository
+	digest := registry.putManifest("app", "rc", manifestMediaTypeV2, `{"schemaVersion":2}`)
+	registry.putManifest("app", "stable", manifestMediaTypeV2, `{"schemaVersion":2}`)
+
+	d := schema.TestResourceDataRaw(t, resourceDockerRegistryImageCopy().Schema, map[string]interface{}{
+		"source":      registry.registry() + "/app:rc",
+		"destination": registry.registry() + "/app:stable",
+	})
+	d.SetId(registry.registry() + "/app:stable")
+	d.Set("sha256_digest", digest)
+
+	if err := resourceDockerRegistryImageCopyDelete(d, &ProviderConfig{RegistryClient: registry.client()}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := registry.manifests["app/stable"]; ok {
+		t.Fatalf("Expected the destination tag to be deleted")
+	}
+	if _, ok := registry.manifests["app/rc"]; !ok {
+		t.Fatalf("Expected the source tag to be kept")
+	}
+	if _, ok := registry.manifests["app/"+digest]; !ok {
+		t.Fatalf("Expected the shared manifest to be kept")
+	}
+}
+
+func TestAccDockerRegistryImageCopy_moved(t *testing.T) {
+	var digest string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testDockerRegistryImageNotInRegistry(createPushImageOptions("127.0.0.1:15000/tftest-copy:latest")),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerRegistryImageCopyConfig, "127.0.0.1:15000", "127.0.0.1:15000/tftest-service:v1", "127.0.0.1:15000/tftest-copy:latest"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckDockerRegistryImageCopyDigest("docker_registry_image_copy.foo", &digest),
+				),
+			},
+			{
+				// the moved source is copied again
+				Config: fmt.Sprintf(testAccDockerRegistryImageCopyConfig, "127.0.0.1:15000", "127.0.0.1:15000/tftest-service:v2", "127.0.0.1:15000/tftest-copy:latest"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("docker_registry_image_copy.foo", "sha256_digest", "docker_registry_image_copy.foo", "source_digest"),
+					func(s *terraform.State) error {
+						if s.RootModule().Resources["docker_registry_image_copy.foo"].Primary.Attributes["sha256_digest"] == digest {
+							return fmt.Errorf("Image was not copied again")
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckDockerRegistryImageCopyDigest(n string, digest *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		*digest = rs.Primary.Attributes["sha256_digest"]
+		return nil
+	}
+}
+
+const testAccDockerRegistryImageCopyConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+	}
+}
+
+resource "docker_registry_image_copy" "foo" {
+	provider    = "docker.private"
+	source      = "%s"
+	destination = "%s"
+}
+`
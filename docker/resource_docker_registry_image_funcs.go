@@ -1,11 +1,10 @@
 package docker
 
 import (
-	"archive/tar"
-	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -13,18 +12,25 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/go-units"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	imageConfigMediaType = "application/vnd.docker.container.image.v1+json"
+	layerMediaType       = "application/vnd.docker.image.rootfs.diff.tar.gzip"
 )
 
 type internalPushImageOptions struct {
@@ -54,6 +60,12 @@ func createImageBuildOptions(buildOptions map[string]interface{}) types.ImageBui
 		return stringArray
 	}
 
+	// the sizes are already validated by the schema
+	readSize := func(size interface{}) int64 {
+		sizeInBytes, _ := parseSize(size.(string), sizeUnitBytes)
+		return sizeInBytes
+	}
+
 	mapToBuildArgs := func(buildArgsOptions map[string]interface{}) map[string]*string {
 		buildArgs := make(map[string]*string, len(buildArgsOptions))
 		for k, v := range buildArgsOptions {
@@ -108,11 +120,11 @@ func createImageBuildOptions(buildOptions map[string]interface{}) types.ImageBui
 	buildImageOptions.CPUShares = int64(buildOptions["cpu_shares"].(int))
 	buildImageOptions.CPUQuota = int64(buildOptions["cpu_quota"].(int))
 	buildImageOptions.CPUPeriod = int64(buildOptions["cpu_period"].(int))
-	buildImageOptions.Memory = int64(buildOptions["memory"].(int))
-	buildImageOptions.MemorySwap = int64(buildOptions["memory_swap"].(int))
+	buildImageOptions.Memory = readSize(buildOptions["memory"])
+	buildImageOptions.MemorySwap = readSize(buildOptions["memory_swap"])
 	buildImageOptions.CgroupParent = buildOptions["cgroup_parent"].(string)
 	buildImageOptions.NetworkMode = buildOptions["network_mode"].(string)
-	buildImageOptions.ShmSize = int64(buildOptions["shm_size"].(int))
+	buildImageOptions.ShmSize = readSize(buildOptions["shm_size"])
 	buildImageOptions.Dockerfile = buildOptions["dockerfile"].(string)
 	buildImageOptions.Ulimits = readULimits(buildOptions["ulimit"].([]interface{}))
 	buildImageOptions.BuildArgs = mapToBuildArgs(buildOptions["build_args"].(map[string]interface{}))
@@ -132,7 +144,7 @@ func createImageBuildOptions(buildOptions map[string]interface{}) types.ImageBui
 	return buildImageOptions
 }
 
-func buildDockerRegistryImage(client *client.Client, buildOptions map[string]interface{}, fqName string) error {
+func buildDockerRegistryImage(ctx context.Context, client *client.Client, buildOptions map[string]interface{}, fqName string) error {
 
 	type ErrorDetailMessage struct {
 		Code    int    `json:"code,omitempty"`
@@ -169,20 +181,25 @@ func buildDockerRegistryImage(client *client.Client, buildOptions map[string]int
 	imageBuildOptions := createImageBuildOptions(buildOptions)
 	imageBuildOptions.Tags = []string{fqName}
 
-	// the tar hash is passed only after the initial creation
-	buildContext := buildOptions["context"].(string)
-	if lastIndex := strings.LastIndexByte(buildContext, ':'); lastIndex > -1 {
-		buildContext = buildContext[:lastIndex]
-	}
-	dockerContextTarPath, err := buildDockerImageContextTar(buildContext)
+	buildContext, err := homedir.Expand(buildOptions["context"].(string))
 	if err != nil {
 		return fmt.Errorf("Unable to build context %v", err)
 	}
-	defer os.Remove(dockerContextTarPath)
-	dockerBuildContext, err := os.Open(dockerContextTarPath)
+	if _, err = os.Stat(buildContext); err != nil {
+		return fmt.Errorf("Unable to read build context - %v", err.Error())
+	}
+	excludes, err := readBuildContextExcludes(buildContext, imageBuildOptions.Dockerfile)
+	if err != nil {
+		return fmt.Errorf("Unable to read .dockerignore of build context %v", err)
+	}
+	// the context is streamed to the daemon without a temporary file
+	dockerBuildContext, err := getBuildContext(buildContext, excludes)
+	if err != nil {
+		return err
+	}
 	defer dockerBuildContext.Close()
 
-	buildResponse, err := client.ImageBuild(context.Background(), dockerBuildContext, imageBuildOptions)
+	buildResponse, err := client.ImageBuild(ctx, dockerBuildContext, imageBuildOptions)
 	if err != nil {
 		return err
 	}
@@ -196,239 +213,360 @@ func buildDockerRegistryImage(client *client.Client, buildOptions map[string]int
 	return nil
 }
 
-func buildDockerImageContextTar(buildContext string) (string, error) {
-	// Create our Temp File:  This will create a filename like /tmp/terraform-provider-docker-123456.tar
-	tmpFile, err := ioutil.TempFile(os.TempDir(), "terraform-provider-docker-*.tar")
-	if err != nil {
-		return "", fmt.Errorf("Cannot create temporary file - %v", err.Error())
-	}
+const (
+	// pushImageRetries is the number of times a push which failed
+	// with a retriable error is retried
+	pushImageRetries = 3
+	// pushImageRetryDelay is the delay before the first retry, which
+	// doubles with each retry
+	pushImageRetryDelay = 2 * time.Second
+)
 
-	defer tmpFile.Close()
+// the kinds of errors of a push, only network and blob upload errors are retried
+const (
+	pushErrorUnauthorized      = "unauthorized"
+	pushErrorBlobUploadUnknown = "blob upload unknown"
+	pushErrorNetwork           = "network"
+	pushErrorOther             = "other"
+)
 
-	if _, err = os.Stat(buildContext); err != nil {
-		return "", fmt.Errorf("Unable to read build context - %v", err.Error())
+// pushError is an error reported by the daemon while pushing an image
+type pushError struct {
+	kind    string
+	code    int
+	message string
+}
+
+func (e *pushError) Error() string {
+	if e.code != 0 {
+		return fmt.Sprintf("%s (%s, code %d)", e.message, e.kind, e.code)
 	}
+	return fmt.Sprintf("%s (%s)", e.message, e.kind)
+}
 
-	tw := tar.NewWriter(tmpFile)
-	defer tw.Close()
+func (e *pushError) retriable() bool {
+	return e.kind == pushErrorNetwork || e.kind == pushErrorBlobUploadUnknown
+}
 
-	err = filepath.Walk(buildContext, func(file string, info os.FileInfo, err error) error {
+// newPushError classifies the error message of the daemon or the client
+func newPushError(code int, message string) *pushError {
+	kind := pushErrorOther
+	lowerMessage := strings.ToLower(message)
+	switch {
+	case code == 401 || code == 403 ||
+		strings.Contains(lowerMessage, "unauthorized") ||
+		strings.Contains(lowerMessage, "authentication required") ||
+		strings.Contains(lowerMessage, "no basic auth credentials") ||
+		strings.Contains(lowerMessage, "denied"):
+		kind = pushErrorUnauthorized
+	case strings.Contains(lowerMessage, "blob upload unknown") ||
+		strings.Contains(lowerMessage, "blob upload invalid"):
+		kind = pushErrorBlobUploadUnknown
+	case isInterruptedPullError(fmt.Errorf("%s", message)) ||
+		strings.Contains(lowerMessage, "connection refused") ||
+		strings.Contains(lowerMessage, "no such host") ||
+		strings.Contains(lowerMessage, "use of closed network connection"):
+		kind = pushErrorNetwork
+	}
+	return &pushError{kind: kind, code: code, message: message}
+}
 
-		// return on any error
+// pushDockerRegistryImage pushes the image with the auth config of its registry. The
+// layers which were uploaded completely are skipped by the daemon, so a retry after an
+// interrupted upload only uploads the remaining layers.
+func pushDockerRegistryImage(ctx context.Context, client *client.Client, pushOpts internalPushImageOptions, auth types.AuthConfig) error {
+	pushOptions := types.ImagePushOptions{}
+	if auth != (types.AuthConfig{}) {
+		authBytes, err := json.Marshal(auth)
 		if err != nil {
-			return err
+			return fmt.Errorf("Error creating push options: %s", err)
 		}
+		pushOptions.RegistryAuth = base64.URLEncoding.EncodeToString(authBytes)
+	}
 
-		// create a new dir/file header
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
+	for retry := 0; ; retry++ {
+		err := pushDockerRegistryImageOnce(ctx, client, pushOpts.FqName, pushOptions)
+		if err == nil {
+			log.Printf("[DEBUG] Pushed image: %s", pushOpts.FqName)
+			return nil
 		}
-
-		// update the name to correctly reflect the desired destination when untaring
-		header.Name = strings.TrimPrefix(strings.Replace(file, buildContext, "", -1), string(filepath.Separator))
-
-		// write the header
-		if err := tw.WriteHeader(header); err != nil {
-			return err
+		if retry == pushImageRetries || !err.retriable() {
+			if err.kind == pushErrorUnauthorized {
+				return fmt.Errorf("Error pushing image %s, check the registry_auth of the provider: %s", pushOpts.FqName, err)
+			}
+			return fmt.Errorf("Error pushing image %s: %s", pushOpts.FqName, err)
 		}
 
-		// return on non-regular files (thanks to [kumo](https://medium.com/@komuw/just-like-you-did-fbdd7df829d3) for this suggested update)
-		if !info.Mode().IsRegular() {
-			return nil
+		delay := (1 << uint(retry)) * pushImageRetryDelay
+		log.Printf("[WARN] Push of image %s failed, retrying in %s: %s", pushOpts.FqName, delay, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Error pushing image %s: %s", pushOpts.FqName, err)
+		case <-time.After(delay):
 		}
+	}
+}
 
-		// open files for taring
-		f, err := os.Open(file)
-		if err != nil {
-			return err
+func pushDockerRegistryImageOnce(ctx context.Context, client *client.Client, image string, pushOptions types.ImagePushOptions) *pushError {
+	out, err := client.ImagePush(ctx, image, pushOptions)
+	if err != nil {
+		return newPushError(0, err.Error())
+	}
+	defer out.Close()
+
+	return decodePushMessages(image, out)
+}
+
+// decodePushMessages logs the status of a push and returns the error the
+// daemon reports in the message stream, or the error reading the stream
+func decodePushMessages(image string, body io.Reader) *pushError {
+	dec := json.NewDecoder(body)
+	for {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return newPushError(0, fmt.Sprintf("Problem decoding message from docker daemon: %s", err))
+		}
+		if m.Error != nil {
+			return newPushError(m.Error.Code, m.Error.Message)
+		}
+		if m.ErrorMessage != "" {
+			return newPushError(0, m.ErrorMessage)
 		}
 
-		// copy file data into tar writer
-		if _, err := io.Copy(tw, f); err != nil {
-			return err
+		// the progress of the uploads is not logged
+		if m.Progress != nil && m.Progress.Total > 0 {
+			continue
+		}
+		if m.ID == "" {
+			log.Printf("[DEBUG] Pushing image %s: %s", image, m.Status)
+		} else {
+			log.Printf("[DEBUG] Pushing image %s: %s: %s", image, m.ID, m.Status)
 		}
+	}
+}
 
-		// manually close here after each file operation; defering would cause each file close
-		// to wait until all operations have completed.
-		f.Close()
+// buildAndPushDockerRegistryImagePlatforms builds and pushes the image for each of the platforms
+// of the build options by its digest, and then pushes a manifest list of the images under the
+// tag of the image, so the tag never points to the image of a single platform.
+// It returns the digest of the manifest list.
+func buildAndPushDockerRegistryImagePlatforms(ctx context.Context, client *client.Client, registryClient *registryClient, buildOptions map[string]interface{}, pushOpts internalPushImageOptions) (string, error) {
+	var manifests []registryDescriptor
+	for _, platform := range buildOptions["platforms"].([]interface{}) {
+		platformBuildOptions := make(map[string]interface{}, len(buildOptions))
+		for k, v := range buildOptions {
+			platformBuildOptions[k] = v
+		}
+		platformBuildOptions["platform"] = platform.(string)
 
-		return nil
+		log.Printf("[DEBUG] Building docker image %s for platform %s", pushOpts.FqName, platform)
+		if err := buildDockerRegistryImage(ctx, client, platformBuildOptions, pushOpts.FqName); err != nil {
+			return "", fmt.Errorf("Error building docker image for platform %s: %s", platform, err)
+		}
+		localImage, _, err := client.ImageInspectWithRaw(ctx, pushOpts.FqName)
+		if err != nil {
+			return "", fmt.Errorf("Unable to inspect image %s: %s", pushOpts.FqName, err)
+		}
 
-	})
+		// the daemon can only push tags, so the image is pushed by its digest
+		// with the registry client to not write any tag but the manifest list
+		saved, err := client.ImageSave(ctx, []string{localImage.ID})
+		if err != nil {
+			return "", fmt.Errorf("Unable to save image for platform %s: %s", platform, err)
+		}
+		manifest, err := pushSavedRegistryImage(ctx, registryClient, pushOpts, saved)
+		saved.Close()
+		if err != nil {
+			return "", fmt.Errorf("Error pushing docker image for platform %s: %s", platform, err)
+		}
 
-	return tmpFile.Name(), nil
-}
+		parsedPlatform, err := platforms.Parse(platform.(string))
+		if err != nil {
+			return "", err
+		}
+		manifest.Platform = &registryPlatform{
+			OS:           parsedPlatform.OS,
+			Architecture: parsedPlatform.Architecture,
+			Variant:      parsedPlatform.Variant,
+		}
+		manifests = append(manifests, manifest)
+	}
 
-func getDockerImageContextTarHash(dockerContextTarPath string) (string, error) {
-	hasher := sha256.New()
-	s, err := ioutil.ReadFile(dockerContextTarPath)
+	mediaType, content, err := createManifestList(manifests)
 	if err != nil {
 		return "", err
 	}
-	hasher.Write(s)
-	contextHash := hex.EncodeToString(hasher.Sum(nil))
-	return contextHash, nil
+	log.Printf("[DEBUG] Pushing manifest list of %d images as %s", len(manifests), pushOpts.FqName)
+	digest, err := registryClient.putManifest(ctx, pushOpts.Registry, pushOpts.Repository, pushOpts.Tag, mediaType, content)
+	if err != nil {
+		return "", fmt.Errorf("Error pushing manifest list: %s", err)
+	}
+	return digest, nil
 }
 
-func pushDockerRegistryImage(client *client.Client, pushOpts internalPushImageOptions, username string, password string) error {
-	pushOptions := types.ImagePushOptions{}
-	if username != "" {
-		auth := types.AuthConfig{Username: username, Password: password}
-		authBytes, err := json.Marshal(auth)
-		if err != nil {
-			return fmt.Errorf("Error creating push options: %s", err)
-		}
-		authBase64 := base64.URLEncoding.EncodeToString(authBytes)
-		pushOptions.RegistryAuth = authBase64
+// pushSavedRegistryImage uploads the config and the gzipped layers of an image saved
+// with 'docker save' to the repository and pushes its manifest by its digest, so the
+// manifest is only referenced by the manifest list and no tag is written
+func pushSavedRegistryImage(ctx context.Context, registryClient *registryClient, pushOpts internalPushImageOptions, saved io.Reader) (registryDescriptor, error) {
+	dir, err := ioutil.TempDir("", "docker-registry-image")
+	if err != nil {
+		return registryDescriptor{}, err
+	}
+	defer os.RemoveAll(dir)
+	if err := archive.Untar(saved, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to extract saved image: %s", err)
 	}
 
-	out, err := client.ImagePush(context.Background(), pushOpts.FqName, pushOptions)
+	var savedManifests []struct {
+		Config string
+		Layers []string
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
 	if err != nil {
-		return err
+		return registryDescriptor{}, fmt.Errorf("Unable to read manifest of saved image: %s", err)
+	}
+	if err := json.Unmarshal(content, &savedManifests); err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to parse manifest of saved image: %s", err)
+	}
+	if len(savedManifests) != 1 {
+		return registryDescriptor{}, fmt.Errorf("Expected 1 saved image, got %d", len(savedManifests))
 	}
-	defer out.Close()
 
-	type ErrorMessage struct {
-		Error string
+	config, err := ioutil.ReadFile(filepath.Join(dir, savedManifests[0].Config))
+	if err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to read config of saved image: %s", err)
 	}
-	var errorMessage ErrorMessage
-	buffIOReader := bufio.NewReader(out)
-	for {
-		streamBytes, err := buffIOReader.ReadBytes('\n')
-		if err == io.EOF {
-			break
-		}
-		json.Unmarshal(streamBytes, &errorMessage)
-		if errorMessage.Error != "" {
-			return fmt.Errorf("Error pushing image: %s", errorMessage.Error)
+	manifest := registryManifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaTypeV2,
+		Config: &registryDescriptor{
+			MediaType: imageConfigMediaType,
+			Size:      int64(len(config)),
+			Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(config)),
+		},
+	}
+	if err := pushRegistryBlob(ctx, registryClient, pushOpts, *manifest.Config, bytes.NewReader(config)); err != nil {
+		return registryDescriptor{}, err
+	}
+
+	for _, layerPath := range savedManifests[0].Layers {
+		layer, err := pushSavedRegistryImageLayer(ctx, registryClient, pushOpts, dir, layerPath)
+		if err != nil {
+			return registryDescriptor{}, err
 		}
+		manifest.Layers = append(manifest.Layers, layer)
 	}
-	log.Printf("[DEBUG] Pushed image: %s", pushOpts.FqName)
-	return nil
-}
 
-func getDockerRegistryImageRegistryUserNameAndPassword(
-	pushOpts internalPushImageOptions,
-	providerConfig *ProviderConfig) (string, string) {
-	registry := pushOpts.NormalizedRegistry
-	username := ""
-	password := ""
-	if authConfig, ok := providerConfig.AuthConfigs.Configs[registry]; ok {
-		username = authConfig.Username
-		password = authConfig.Password
-	}
-	return username, password
+	content, err = json.Marshal(manifest)
+	if err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to create manifest: %s", err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	log.Printf("[DEBUG] Pushing manifest %s to %s", digest, pushOpts.Repository)
+	if _, err := registryClient.putManifest(ctx, pushOpts.Registry, pushOpts.Repository, digest, manifestMediaTypeV2, content); err != nil {
+		return registryDescriptor{}, err
+	}
+	return registryDescriptor{
+		MediaType: manifestMediaTypeV2,
+		Size:      int64(len(content)),
+		Digest:    digest,
+	}, nil
 }
 
-func deleteDockerRegistryImage(pushOpts internalPushImageOptions, sha256Digest, username, password string, fallback bool) error {
-	client := http.DefaultClient
-
-	// Allow insecure registries only for ACC tests
-	// cuz we don't have a valid certs for this case
-	if env, okEnv := os.LookupEnv("TF_ACC"); okEnv {
-		if i, errConv := strconv.Atoi(env); errConv == nil && i >= 1 {
-			cfg := &tls.Config{
-				InsecureSkipVerify: true,
-			}
-			client.Transport = &http.Transport{
-				TLSClientConfig: cfg,
-			}
-		}
+// pushSavedRegistryImageLayer compresses the layer of a saved image into a
+// file next to it, as the digest is needed before the upload, and uploads it
+func pushSavedRegistryImageLayer(ctx context.Context, registryClient *registryClient, pushOpts internalPushImageOptions, dir string, layerPath string) (registryDescriptor, error) {
+	layer, err := os.Open(filepath.Join(dir, layerPath))
+	if err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to read layer of saved image: %s", err)
 	}
-
-	req, err := http.NewRequest("DELETE", pushOpts.NormalizedRegistry+"/v2/"+pushOpts.Repository+"/manifests/"+sha256Digest, nil)
+	defer layer.Close()
+	compressed, err := ioutil.TempFile(dir, "layer")
 	if err != nil {
-		return fmt.Errorf("Error deleting registry image: %s", err)
+		return registryDescriptor{}, err
 	}
+	defer compressed.Close()
 
-	if username != "" {
-		req.SetBasicAuth(username, password)
+	hasher := sha256.New()
+	writer := gzip.NewWriter(io.MultiWriter(compressed, hasher))
+	if _, err := io.Copy(writer, layer); err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to compress layer %s: %s", layerPath, err)
 	}
-
-	// We accept schema v2 manifests and manifest lists, and also OCI types
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
-
-	if fallback {
-		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
-		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v1+prettyjws")
+	if err := writer.Close(); err != nil {
+		return registryDescriptor{}, fmt.Errorf("Unable to compress layer %s: %s", layerPath, err)
+	}
+	size, err := compressed.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return registryDescriptor{}, err
+	}
+	if _, err := compressed.Seek(0, io.SeekStart); err != nil {
+		return registryDescriptor{}, err
 	}
 
-	resp, err := client.Do(req)
+	descriptor := registryDescriptor{
+		MediaType: layerMediaType,
+		Size:      size,
+		Digest:    "sha256:" + hex.EncodeToString(hasher.Sum(nil)),
+	}
+	return descriptor, pushRegistryBlob(ctx, registryClient, pushOpts, descriptor, compressed)
+}
 
+// pushRegistryBlob uploads the blob unless the repository already contains it
+func pushRegistryBlob(ctx context.Context, registryClient *registryClient, pushOpts internalPushImageOptions, blob registryDescriptor, content io.Reader) error {
+	exists, err := registryClient.blobExists(ctx, pushOpts.Registry, pushOpts.Repository, blob.Digest)
 	if err != nil {
-		return fmt.Errorf("Error during registry request: %s", err)
+		return err
 	}
-
-	switch resp.StatusCode {
-	// Basic auth was valid or not needed
-	case http.StatusOK, http.StatusAccepted, http.StatusNotFound:
+	if exists {
+		log.Printf("[DEBUG] Blob %s already exists in %s", blob.Digest, pushOpts.Repository)
 		return nil
+	}
 
-	// Either OAuth is required or the basic auth creds were invalid
-	case http.StatusUnauthorized:
-		if strings.HasPrefix(resp.Header.Get("www-authenticate"), "Bearer") {
-			auth := parseAuthHeader(resp.Header.Get("www-authenticate"))
-			params := url.Values{}
-			params.Set("service", auth["service"])
-			params.Set("scope", auth["scope"])
-			tokenRequest, err := http.NewRequest("GET", auth["realm"]+"?"+params.Encode(), nil)
-
-			if err != nil {
-				return fmt.Errorf("Error creating registry request: %s", err)
-			}
-
-			if username != "" {
-				tokenRequest.SetBasicAuth(username, password)
-			}
-
-			tokenResponse, err := client.Do(tokenRequest)
-
-			if err != nil {
-				return fmt.Errorf("Error during registry request: %s", err)
-			}
-
-			if tokenResponse.StatusCode != http.StatusOK {
-				return fmt.Errorf("Got bad response from registry: " + tokenResponse.Status)
-			}
-
-			body, err := ioutil.ReadAll(tokenResponse.Body)
-			if err != nil {
-				return fmt.Errorf("Error reading response body: %s", err)
-			}
-
-			token := &TokenResponse{}
-			err = json.Unmarshal(body, token)
-			if err != nil {
-				return fmt.Errorf("Error parsing OAuth token response: %s", err)
-			}
-
-			req.Header.Set("Authorization", "Bearer "+token.Token)
-			oauthResp, err := client.Do(req)
-			switch oauthResp.StatusCode {
-			case http.StatusOK, http.StatusAccepted, http.StatusNotFound:
-				return nil
-			default:
-				return fmt.Errorf("Got bad response from registry: " + resp.Status)
-			}
+	location, err := registryClient.startBlobUpload(ctx, pushOpts.Registry, pushOpts.Repository, blob.Digest, "")
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Uploading blob %s (%d bytes) to %s", blob.Digest, blob.Size, pushOpts.Repository)
+	return registryClient.uploadBlob(ctx, pushOpts.Registry, pushOpts.Repository, location, blob.Digest, blob.Size, content)
+}
 
+// createManifestList returns the media type and content of a manifest list of the manifests,
+// which is an OCI index if all manifests are OCI manifests, or a Docker manifest list otherwise
+func createManifestList(manifests []registryDescriptor) (string, []byte, error) {
+	mediaType := manifestMediaTypeOCIIndex
+	for _, m := range manifests {
+		if m.MediaType != manifestMediaTypeOCI {
+			mediaType = manifestMediaTypeList
 		}
+	}
 
-		return fmt.Errorf("Bad credentials: " + resp.Status)
+	content, err := json.MarshalIndent(registryManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaType,
+		Manifests:     manifests,
+	}, "", "   ")
+	if err != nil {
+		return "", nil, fmt.Errorf("Unable to create manifest list: %s", err)
+	}
+	return mediaType, content, nil
+}
 
-		// Some unexpected status was given, return an error
-	default:
-		return fmt.Errorf("Got bad response from registry: " + resp.Status)
+// getDockerRegistryImageAuthConfig returns the auth config of the registry of the image,
+// including identity tokens, or an empty auth config if the registry is not configured
+func getDockerRegistryImageAuthConfig(pushOpts internalPushImageOptions, providerConfig *ProviderConfig) types.AuthConfig {
+	if authConfig, ok := providerConfig.AuthConfigs.Configs[pushOpts.NormalizedRegistry]; ok {
+		return authConfig
 	}
+	return types.AuthConfig{}
 }
 
-func getImageDigestWithFallback(opts internalPushImageOptions, username, password string) (string, error) {
-	digest, err := getImageDigest(opts.Registry, opts.Repository, opts.Tag, username, password, false)
+func getImageDigestWithFallback(registryClient *registryClient, opts internalPushImageOptions) (string, error) {
+	ctx := context.Background()
+	digest, err := registryClient.getManifestDigest(ctx, opts.Registry, opts.Repository, opts.Tag, false)
 	if err != nil {
-		digest, err = getImageDigest(opts.Registry, opts.Repository, opts.Tag, username, password, true)
+		digest, err = registryClient.getManifestDigest(ctx, opts.Registry, opts.Repository, opts.Tag, true)
 		if err != nil {
 			return "", fmt.Errorf("Unable to get digest: %s", err)
 		}
@@ -455,47 +593,153 @@ func createPushImageOptions(image string) internalPushImageOptions {
 }
 
 func resourceDockerRegistryImageCreate(d *schema.ResourceData, meta interface{}) error {
-	client := meta.(*ProviderConfig).DockerClient
-	providerConfig := meta.(*ProviderConfig)
 	name := d.Get("name").(string)
 	log.Printf("[DEBUG] Creating docker image %s", name)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
-	pushOpts := createPushImageOptions(name)
+	if err := buildAndPushDockerRegistryImageResource(ctx, d, meta); err != nil {
+		return err
+	}
+	d.SetId(d.Get("sha256_digest").(string))
+	return nil
+}
 
+// buildAndPushDockerRegistryImageResource builds the image if a build is configured, pushes it
+// and records the ID of the pushed local image and the digest of the image on the registry
+func buildAndPushDockerRegistryImageResource(ctx context.Context, d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	providerConfig := meta.(*ProviderConfig)
+	pushOpts := createPushImageOptions(d.Get("name").(string))
+
+	auth := getDockerRegistryImageAuthConfig(pushOpts, providerConfig)
 	if buildOptions, ok := d.GetOk("build"); ok {
 		buildOptionsMap := buildOptions.([]interface{})[0].(map[string]interface{})
-		err := buildDockerRegistryImage(client, buildOptionsMap, pushOpts.FqName)
+		if platforms := buildOptionsMap["platforms"].([]interface{}); len(platforms) > 0 {
+			digest, err := buildAndPushDockerRegistryImagePlatforms(ctx, client, providerConfig.RegistryClient, buildOptionsMap, pushOpts)
+			if err != nil {
+				return err
+			}
+			contextHash, err := getBuildContextHash(buildOptionsMap["context"].(string), buildOptionsMap["dockerfile"].(string))
+			if err != nil {
+				return err
+			}
+			d.Set("build_context_hash", contextHash)
+			// only the image of the last platform is kept under the name locally
+			d.Set("local_image_id", "")
+			d.Set("sha256_digest", digest)
+			return nil
+		}
+
+		err := buildDockerRegistryImage(ctx, client, buildOptionsMap, pushOpts.FqName)
 		if err != nil {
 			return fmt.Errorf("Error building docker image: %s", err)
 		}
+		contextHash, err := getBuildContextHash(buildOptionsMap["context"].(string), buildOptionsMap["dockerfile"].(string))
+		if err != nil {
+			return err
+		}
+		d.Set("build_context_hash", contextHash)
 	}
 
-	username, password := getDockerRegistryImageRegistryUserNameAndPassword(pushOpts, providerConfig)
-	if err := pushDockerRegistryImage(client, pushOpts, username, password); err != nil {
-		return fmt.Errorf("Error pushing docker image: %s", err)
+	localImage, _, err := client.ImageInspectWithRaw(ctx, pushOpts.FqName)
+	if err != nil && !strings.Contains(err.Error(), "No such image") {
+		return fmt.Errorf("Unable to inspect image %s: %s", pushOpts.FqName, err)
+	}
+
+	if err := pushDockerRegistryImage(ctx, client, pushOpts, auth); err != nil {
+		return err
 	}
 
-	digest, err := getImageDigestWithFallback(pushOpts, username, password)
+	digest, err := getImageDigestWithFallback(providerConfig.RegistryClient, pushOpts)
 	if err != nil {
 		return fmt.Errorf("Unable to create image, image not found: %s", err)
 	}
-	d.SetId(digest)
+	d.Set("local_image_id", localImage.ID)
 	d.Set("sha256_digest", digest)
 	return nil
 }
 
+// resourceDockerRegistryImageCustomizeDiff plans to push the image again if the tag
+// on the registry points to another image than the pushed one, or if the local image
+// was replaced, e.g. by a docker_image build
+func resourceDockerRegistryImageCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffRegistryImageBuildContextHash(d); err != nil {
+		return err
+	}
+	if d.Id() == "" || d.HasChange("name") || d.HasChange("build") {
+		return nil
+	}
+
+	pushOpts := createPushImageOptions(d.Get("name").(string))
+	remoteDigest, err := getImageDigestWithFallback(meta.(*ProviderConfig).RegistryClient, pushOpts)
+	if err != nil {
+		log.Printf("[WARN] Unable to check the digest of image %s on the registry: %s", pushOpts.Name, err)
+	} else if remoteDigest != d.Get("sha256_digest").(string) {
+		log.Printf("[DEBUG] Image %s on the registry moved from %s to %s", pushOpts.Name, d.Get("sha256_digest").(string), remoteDigest)
+		return d.SetNewComputed("sha256_digest")
+	}
+
+	// only the local images of single-platform pushes are recorded
+	localImageID := d.Get("local_image_id").(string)
+	if localImageID == "" {
+		return nil
+	}
+	localImage, _, err := meta.(*ProviderConfig).DockerClient.ImageInspectWithRaw(context.Background(), pushOpts.FqName)
+	if err != nil {
+		if !strings.Contains(err.Error(), "No such image") {
+			log.Printf("[WARN] Unable to inspect local image %s: %s", pushOpts.FqName, err)
+		}
+		return nil
+	}
+	if localImage.ID != localImageID {
+		log.Printf("[DEBUG] Local image %s changed from %s to %s", pushOpts.FqName, localImageID, localImage.ID)
+		if err := d.SetNew("local_image_id", localImage.ID); err != nil {
+			return err
+		}
+		return d.SetNewComputed("sha256_digest")
+	}
+	return nil
+}
+
+// customizeDiffRegistryImageBuildContextHash plans to build and push the
+// image again if the content of the build context changed
+func customizeDiffRegistryImageBuildContextHash(d *schema.ResourceDiff) error {
+	builds := d.Get("build").([]interface{})
+	if len(builds) == 0 || builds[0] == nil || !d.NewValueKnown("build") {
+		return nil
+	}
+	build := builds[0].(map[string]interface{})
+
+	contextHash, err := getBuildContextHash(build["context"].(string), build["dockerfile"].(string))
+	if err != nil {
+		return err
+	}
+	oldContextHash := d.Get("build_context_hash").(string)
+	if contextHash == oldContextHash {
+		return nil
+	}
+	log.Printf("[DEBUG] Build context hash of image %s changed to %s", d.Get("name").(string), contextHash)
+	if err := d.SetNew("build_context_hash", contextHash); err != nil {
+		return err
+	}
+	// images built before the hash was stored only adopt it
+	if d.Id() != "" && oldContextHash != "" {
+		return d.ForceNew("build_context_hash")
+	}
+	return nil
+}
+
 func resourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
 	providerConfig := meta.(*ProviderConfig)
 	name := d.Get("name").(string)
 	pushOpts := createPushImageOptions(name)
-	username, password := getDockerRegistryImageRegistryUserNameAndPassword(pushOpts, providerConfig)
-	digest, err := getImageDigestWithFallback(pushOpts, username, password)
-	if err != nil {
+	// the digest of the pushed image is kept to detect a moved tag
+	if _, err := getImageDigestWithFallback(providerConfig.RegistryClient, pushOpts); err != nil {
 		log.Printf("Got error getting registry image digest: %s", err)
 		d.SetId("")
 		return nil
 	}
-	d.Set("sha256_digest", digest)
 	return nil
 }
 
@@ -506,11 +750,20 @@ func resourceDockerRegistryImageDelete(d *schema.ResourceData, meta interface{})
 	providerConfig := meta.(*ProviderConfig)
 	name := d.Get("name").(string)
 	pushOpts := createPushImageOptions(name)
-	username, password := getDockerRegistryImageRegistryUserNameAndPassword(pushOpts, providerConfig)
 	digest := d.Get("sha256_digest").(string)
-	err := deleteDockerRegistryImage(pushOpts, digest, username, password, false)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	if !d.Get("delete_shared_digest").(bool) {
+		tagDeleted, err := deleteSharedRegistryImageTag(ctx, providerConfig.RegistryClient, pushOpts.Registry, pushOpts.Repository, pushOpts.Tag, digest)
+		if err != nil || tagDeleted {
+			return err
+		}
+	}
+
+	err := providerConfig.RegistryClient.deleteManifest(ctx, pushOpts.Registry, pushOpts.Repository, digest, false)
 	if err != nil {
-		err = deleteDockerRegistryImage(pushOpts, pushOpts.Tag, username, password, true)
+		err = providerConfig.RegistryClient.deleteManifest(ctx, pushOpts.Registry, pushOpts.Repository, pushOpts.Tag, true)
 		if err != nil {
 			return fmt.Errorf("Got error getting registry image digest: %s", err)
 		}
@@ -518,6 +771,68 @@ func resourceDockerRegistryImageDelete(d *schema.ResourceData, meta interface{})
 	return nil
 }
 
+// deleteSharedRegistryImageTag deletes only the tag of an image if other tags of the repository
+// point to its digest, as deleting the manifest deletes all tags pointing to it. It returns
+// false if no other tag points to the digest, so the manifest can be deleted.
+func deleteSharedRegistryImageTag(ctx context.Context, registryClient *registryClient, registry string, repository string, tag string, digest string) (bool, error) {
+	name := registry + "/" + repository + ":" + tag
+	sharedTags, err := getDockerRegistryImageSharedTags(ctx, registryClient, registry, repository, tag, digest)
+	if err != nil {
+		// e.g. a registry without tag listing, where other tags might still point to the digest
+		return false, fmt.Errorf("Unable to check whether other tags of %s point to %s: %s. Set 'delete_shared_digest' to delete "+
+			"the image with all its tags, or 'keep_remotely' to keep it", repository, digest, err)
+	}
+	if len(sharedTags) == 0 {
+		return false, nil
+	}
+
+	log.Printf("[DEBUG] Tags %s also point to %s, deleting tag %s only", strings.Join(sharedTags, ", "), digest, tag)
+	if err := registryClient.deleteManifest(ctx, registry, repository, tag, false); err != nil {
+		return false, fmt.Errorf("Unable to delete tag %s, which the registry might not support: %s. "+
+			"The image %s is kept because the tags %s point to it as well. Set 'delete_shared_digest' to delete "+
+			"the image with all its tags, or 'keep_remotely' to keep it", name, err, digest, strings.Join(sharedTags, ", "))
+	}
+	return true, nil
+}
+
+// getDockerRegistryImageSharedTags returns the other tags of the repository of the image
+// which point to the digest. Tags without a v2 manifest cannot point to the digest and are
+// skipped, other errors are returned as the tag might point to the digest.
+func getDockerRegistryImageSharedTags(ctx context.Context, registryClient *registryClient, registry string, repository string, tag string, digest string) ([]string, error) {
+	tags, err := registryClient.listTags(ctx, registry, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedTags := []string{}
+	for _, otherTag := range tags {
+		if otherTag == tag {
+			continue
+		}
+		tagDigest, err := registryClient.headManifestDigest(ctx, registry, repository, otherTag)
+		if err != nil {
+			if _, ok := err.(*unsupportedRegistryError); ok {
+				log.Printf("[DEBUG] Tag %s of %s has no v2 manifest: %s", otherTag, repository, err)
+				continue
+			}
+			return nil, fmt.Errorf("Unable to read the digest of tag %s: %s", otherTag, err)
+		}
+		if tagDigest == digest {
+			sharedTags = append(sharedTags, otherTag)
+		}
+	}
+	return sharedTags, nil
+}
+
 func resourceDockerRegistryImageUpdate(d *schema.ResourceData, meta interface{}) error {
+	if d.HasChange("sha256_digest") || d.HasChange("local_image_id") {
+		log.Printf("[DEBUG] Pushing docker image %s again", d.Get("name").(string))
+		ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutUpdate))
+		defer cancel()
+
+		if err := buildAndPushDockerRegistryImageResource(ctx, d, meta); err != nil {
+			return err
+		}
+	}
 	return resourceDockerRegistryImageRead(d, meta)
 }
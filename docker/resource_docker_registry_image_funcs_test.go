@@ -1,10 +1,20 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -141,6 +151,420 @@ func TestAccDockerRegistryImageResource_buildAndKeep(t *testing.T) {
 	})
 }
 
+func TestAccDockerRegistryImageResource_remoteDrift(t *testing.T) {
+	pushOptions := createPushImageOptions("127.0.0.1:15000/tftest-dockerregistryimage-drift:1.0")
+	buildContext := "../scripts/testing/docker_registry_image_context"
+	config := fmt.Sprintf(testBuildDockerRegistryImageNoKeepConfig, pushOptions.Registry, pushOptions.Name, buildContext)
+	overwrite := func() {
+		registryClient := testAccProvider.Meta().(*ProviderConfig).RegistryClient
+		source := parseRegistryImageName("127.0.0.1:15000/tftest-service:v1")
+		if _, _, err := copyRegistryImage(context.Background(), registryClient, source, parseRegistryImageName(pushOptions.Name)); err != nil {
+			t.Fatalf("Unable to overwrite image: %s", err)
+		}
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testDockerRegistryImageNotInRegistry(pushOptions),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("docker_registry_image.foo", "sha256_digest"),
+					resource.TestCheckResourceAttrSet("docker_registry_image.foo", "local_image_id"),
+				),
+			},
+			{
+				// the overwritten tag is planned to be pushed again
+				PreConfig:          overwrite,
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testDockerRegistryImageDigest("docker_registry_image.foo", pushOptions),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerRegistryImageResource_buildPlatforms(t *testing.T) {
+	pushOptions := createPushImageOptions("127.0.0.1:15000/tftest-dockerregistryimage-platforms:1.0")
+	context := "../scripts/testing/docker_registry_image_context"
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testDockerRegistryImageNotInRegistry(pushOptions),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testBuildDockerRegistryImagePlatformsConfig, pushOptions.Registry, pushOptions.Name, context),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("docker_registry_image.foo", "sha256_digest"),
+					testDockerRegistryImageManifestList(pushOptions, []string{"linux/amd64", "linux/arm64"}),
+				),
+			},
+		},
+	})
+}
+
+func TestPushSavedRegistryImage(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+	registry.bearerAuth = true
+
+	// the layout of 'docker save'
+	config := `{"architecture":"arm64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	save := func() *bytes.Buffer {
+		saved := &bytes.Buffer{}
+		writer := tar.NewWriter(saved)
+		for _, file := range [][2]string{
+			{"abc/VERSION", "1.0"},
+			{"abc/layer.tar", "layer"},
+			{"config.json", config},
+			{"manifest.json", `[{"Config":"config.json","RepoTags":["foo:1.0"],"Layers":["abc/layer.tar"]}]`},
+		} {
+			if err := writer.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			writer.Write([]byte(file[1]))
+		}
+		writer.Close()
+		return saved
+	}
+
+	pushOpts := createPushImageOptions(registry.registry() + "/app:1.0")
+	manifest, err := pushSavedRegistryImage(context.Background(), registry.client(), pushOpts, save())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if manifest.MediaType != manifestMediaTypeV2 {
+		t.Fatalf("Expected a v2 manifest, got %s", manifest.MediaType)
+	}
+
+	// the manifest is only stored under its digest
+	for key := range registry.manifests {
+		if key != "app/"+manifest.Digest {
+			t.Fatalf("Expected only the manifest %s, got %s", manifest.Digest, key)
+		}
+	}
+	pushed, _, _, err := registry.client().getManifest(context.Background(), pushOpts.Registry, pushOpts.Repository, manifest.Digest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if pushed.Config.Digest != fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(config))) || len(pushed.Layers) != 1 || pushed.Layers[0].MediaType != layerMediaType {
+		t.Fatalf("Unexpected manifest %#v", pushed)
+	}
+	layer, err := gzip.NewReader(bytes.NewReader(registry.blobs["app/"+pushed.Layers[0].Digest]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if content, _ := ioutil.ReadAll(layer); string(content) != "layer" {
+		t.Fatalf("Expected the gzipped layer, got %q", content)
+	}
+
+	// pushing again does not upload existing blobs
+	registry.uploads = 0
+	if _, err := pushSavedRegistryImage(context.Background(), registry.client(), pushOpts, save()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if registry.uploads != 0 {
+		t.Fatalf("Expected no uploads, got %d", registry.uploads)
+	}
+}
+
+func TestCreateManifestList(t *testing.T) {
+	manifests := []registryDescriptor{
+		{MediaType: manifestMediaTypeOCI, Size: 500, Digest: "sha256:a", Platform: &registryPlatform{OS: "linux", Architecture: "amd64"}},
+		{MediaType: manifestMediaTypeOCI, Size: 400, Digest: "sha256:b", Platform: &registryPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+
+	mediaType, content, err := createManifestList(manifests)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if mediaType != manifestMediaTypeOCIIndex {
+		t.Fatalf("Expected an OCI index of OCI manifests, got %s", mediaType)
+	}
+	expected := `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.index.v1+json",
+   "manifests": [
+      {
+         "mediaType": "application/vnd.oci.image.manifest.v1+json",
+         "size": 500,
+         "digest": "sha256:a",
+         "platform": {
+            "architecture": "amd64",
+            "os": "linux"
+         }
+      },
+      {
+         "mediaType": "application/vnd.oci.image.manifest.v1+json",
+         "size": 400,
+         "digest": "sha256:b",
+         "platform": {
+            "architecture": "arm",
+            "os": "linux",
+            "variant": "v7"
+         }
+      }
+   ]
+}`
+	if string(content) != expected {
+		t.Fatalf("Expected manifest list\n%s\ngot\n%s", expected, content)
+	}
+
+	manifests[1].MediaType = manifestMediaTypeV2
+	if mediaType, _, _ := createManifestList(manifests); mediaType != manifestMediaTypeList {
+		t.Fatalf("Expected a manifest list of Docker manifests, got %s", mediaType)
+	}
+}
+
+func TestDockerRegistryImageBuildContextHash(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "tf-test-registry-build-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	// the custom Dockerfile is matched by the .dockerignore file
+	for name, content := range map[string]string{
+		"app.dockerfile": "FROM alpine",
+		".dockerignore":  "*.log\n*.dockerfile\n",
+		"app.txt":        "foo",
+	} {
+		if err := ioutil.WriteFile(path.Join(contextDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		contextHash, err := getBuildContextHash(contextDir, "app.dockerfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contextHash
+	}
+	initialHash := hash()
+
+	// a fresh checkout changes the modification times but not the content
+	mtime := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path.Join(contextDir, "app.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(contextDir, "build.log"), []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hash() != initialHash {
+		t.Fatal("Expected the hash to be kept for unchanged content")
+	}
+
+	if err := ioutil.WriteFile(path.Join(contextDir, "app.dockerfile"), []byte("FROM alpine:3.12"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hash() == initialHash {
+		t.Fatal("Expected the hash to change for a changed Dockerfile")
+	}
+}
+
+func TestMigrateRegistryImageContextHash(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "tf-test-registry-build-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+	if err := ioutil.WriteFile(path.Join(contextDir, "app.dockerfile"), []byte("FROM alpine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state of earlier versions contains the hash of the context tar
+	v0State := map[string]interface{}{
+		"name": "127.0.0.1:15000/foo:1.0",
+		"build": []interface{}{
+			map[string]interface{}{
+				"context":    contextDir + ":8b8e4e5c1a6c1a0e8a9b5e0e5d6b4b2a8c9b2b6e1d1f3e9c7b9a0d0e4c3b2a1f",
+				"dockerfile": "app.dockerfile",
+			},
+		},
+	}
+	v1State := migrateRegistryImageContextHash(v0State)
+
+	if context := v1State["build"].([]interface{})[0].(map[string]interface{})["context"]; context != contextDir {
+		t.Fatalf("Expected the context %s, got %s", contextDir, context)
+	}
+	expected, err := getBuildContextHash(contextDir, "app.dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contextHash := v1State["build_context_hash"]; contextHash != expected {
+		t.Fatalf("Expected the build context hash %s, got %v", expected, contextHash)
+	}
+
+	// states without a build are kept
+	if state := migrateRegistryImageContextHash(map[string]interface{}{"name": "foo"}); len(state) != 1 {
+		t.Fatalf("Unexpected state %v", state)
+	}
+}
+
+func TestDecodePushMessages(t *testing.T) {
+	body := `{"status":"The push refers to repository [127.0.0.1:15000/tftest-service]"}
+{"status":"Preparing","progressDetail":{},"id":"3e207b409db3"}
+{"status":"Pushing","progressDetail":{"current":512,"total":5595136},"progress":"[>   ] 512B/5.595MB","id":"3e207b409db3"}
+{"status":"Layer already exists","progressDetail":{},"id":"3e207b409db3"}
+{"status":"v1: digest: sha256:a1b2 size: 528"}
+{"progressDetail":{},"aux":{"Tag":"v1","Digest":"sha256:a1b2","Size":528}}
+`
+	if err := decodePushMessages("127.0.0.1:15000/tftest-service:v1", strings.NewReader(body)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		body      string
+		kind      string
+		retriable bool
+	}{
+		{
+			body:      `{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}`,
+			kind:      pushErrorUnauthorized,
+			retriable: false,
+		},
+		{
+			body:      `{"errorDetail":{"code":401,"message":"denied: requested access to the resource is denied"},"error":"denied: requested access to the resource is denied"}`,
+			kind:      pushErrorUnauthorized,
+			retriable: false,
+		},
+		{
+			body:      `{"errorDetail":{"message":"blob upload unknown"},"error":"blob upload unknown"}`,
+			kind:      pushErrorBlobUploadUnknown,
+			retriable: true,
+		},
+		{
+			body:      `{"errorDetail":{"message":"Put https://127.0.0.1:15000/v2/: read: connection reset by peer"},"error":"Put https://127.0.0.1:15000/v2/: read: connection reset by peer"}`,
+			kind:      pushErrorNetwork,
+			retriable: true,
+		},
+		// the stream ends in the middle of a message
+		{
+			body:      `{"status":"Pushing","progre`,
+			kind:      pushErrorNetwork,
+			retriable: true,
+		},
+		{
+			body:      `{"errorDetail":{"message":"manifest invalid: manifest invalid"},"error":"manifest invalid: manifest invalid"}`,
+			kind:      pushErrorOther,
+			retriable: false,
+		},
+	}
+
+	for _, c := range cases {
+		err := decodePushMessages("127.0.0.1:15000/tftest-service:v1", strings.NewReader(c.body))
+		if err == nil {
+			t.Fatalf("Expected an error for %s", c.body)
+		}
+		if err.kind != c.kind || err.retriable() != c.retriable {
+			t.Fatalf("Expected an error of kind %q (retriable: %t) for %s, got %q (retriable: %t)", c.kind, c.retriable, c.body, err.kind, err.retriable())
+		}
+	}
+}
+
+func TestGetDockerRegistryImageAuthConfig(t *testing.T) {
+	auth := types.AuthConfig{
+		ServerAddress: normalizeRegistryAddress("registry.example.com"),
+		Username:      "<token>",
+		IdentityToken: "fooIdentityToken",
+	}
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Configs: map[string]types.AuthConfig{auth.ServerAddress: auth},
+	}}
+
+	if actual := getDockerRegistryImageAuthConfig(createPushImageOptions("registry.example.com/foo:1.0"), providerConfig); actual != auth {
+		t.Fatalf("Expected the auth config %+v, got %+v", auth, actual)
+	}
+	if actual := getDockerRegistryImageAuthConfig(createPushImageOptions("other.example.com/foo:1.0"), providerConfig); actual != (types.AuthConfig{}) {
+		t.Fatalf("Expected no auth config for another registry, got %+v", actual)
+	}
+}
+
+func TestDockerRegistryImageDeleteSharedDigest(t *testing.T) {
+	registry := newTestRegistry()
+	defer registry.Close()
+
+	cases := []struct {
+		tags               []string
+		v1Tags             []string
+		tagDeletion        bool
+		noTagList          bool
+		deleteSharedDigest bool
+		expectError        bool
+		expectedTags       []string
+	}{
+		// the only tag is deleted with the manifest
+		{tags: []string{"1.0"}},
+		// the shared manifest is kept if tags cannot be deleted
+		{tags: []string{"1.0", "latest"}, expectError: true, expectedTags: []string{"1.0", "latest"}},
+		// the tag is deleted if supported
+		{tags: []string{"1.0", "latest"}, tagDeletion: true, expectedTags: []string{"latest"}},
+		// the manifest is deleted with all its tags on purpose
+		{tags: []string{"1.0", "latest"}, deleteSharedDigest: true},
+		// tags with schema v1 manifests cannot point to the digest
+		{tags: []string{"1.0"}, v1Tags: []string{"old"}, expectedTags: []string{"old"}},
+		// without tag listing the manifest is kept as other tags might point to it
+		{tags: []string{"1.0", "latest"}, noTagList: true, expectError: true, expectedTags: []string{"1.0", "latest"}},
+		{tags: []string{"1.0"}, noTagList: true, expectError: true, expectedTags: []string{"1.0"}},
+		// unless it is deleted on purpose
+		{tags: []string{"1.0", "latest"}, noTagList: true, deleteSharedDigest: true},
+	}
+
+	for _, c := range cases {
+		var digest string
+		for _, tag := range c.tags {
+			digest = registry.putManifest("app", tag, manifestMediaTypeV2, `{"schemaVersion":2}`)
+		}
+		for _, tag := range c.v1Tags {
+			registry.putManifest("app", tag, manifestMediaTypeV1Signed, `{"schemaVersion":1}`)
+		}
+		registry.tagDeletion = c.tagDeletion
+		registry.noTagList = c.noTagList
+		registry.manifestGets = 0
+
+		d := schema.TestResourceDataRaw(t, resourceDockerRegistryImage().Schema, map[string]interface{}{
+			"name":                 registry.registry() + "/app:1.0",
+			"delete_shared_digest": c.deleteSharedDigest,
+		})
+		d.SetId(digest)
+		d.Set("sha256_digest", digest)
+
+		err := resourceDockerRegistryImageDelete(d, &ProviderConfig{RegistryClient: registry.client()})
+		if c.expectError {
+			if err == nil || !strings.Contains(err.Error(), "delete_shared_digest") {
+				t.Fatalf("Expected an error about the shared digest of tags %v, got %v", c.tags, err)
+			}
+		} else if err != nil {
+			t.Fatalf("Unexpected error for tags %v: %s", c.tags, err)
+		}
+		// the digests of the tags are read with HEAD requests
+		if registry.manifestGets != 0 {
+			t.Fatalf("Expected no GET requests of manifests for tags %v, got %d", c.tags, registry.manifestGets)
+		}
+
+		registry.noTagList = false
+		tags, err := registry.client().listTags(context.Background(), registry.registry(), "app")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(tags, c.expectedTags) {
+			t.Fatalf("Expected tags %v after deleting 1.0 of %v, got %v", c.expectedTags, c.tags, tags)
+		}
+
+		// reset the registry
+		for _, tag := range append(tags, digest) {
+			delete(registry.manifests, "app/"+tag)
+		}
+	}
+}
+
 func TestAccDockerRegistryImageResource_pushMissingImage(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:  func() { testAccPreCheck(t) },
@@ -157,8 +581,7 @@ func TestAccDockerRegistryImageResource_pushMissingImage(t *testing.T) {
 func testDockerRegistryImageNotInRegistry(pushOpts internalPushImageOptions) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		providerConfig := testAccProvider.Meta().(*ProviderConfig)
-		username, password := getDockerRegistryImageRegistryUserNameAndPassword(pushOpts, providerConfig)
-		digest, _ := getImageDigestWithFallback(pushOpts, username, password)
+		digest, _ := getImageDigestWithFallback(providerConfig.RegistryClient, pushOpts)
 		if digest != "" {
 			return fmt.Errorf("image found")
 		}
@@ -166,16 +589,54 @@ func testDockerRegistryImageNotInRegistry(pushOpts internalPushImageOptions) res
 	}
 }
 
+func testDockerRegistryImageManifestList(pushOpts internalPushImageOptions, platforms []string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		registryClient := testAccProvider.Meta().(*ProviderConfig).RegistryClient
+		manifest, mediaType, _, err := registryClient.getManifest(context.Background(), pushOpts.Registry, pushOpts.Repository, pushOpts.Tag)
+		if err != nil {
+			return err
+		}
+		if !isManifestList(mediaType) {
+			return fmt.Errorf("Image %s is not a manifest list: %s", pushOpts.Name, mediaType)
+		}
+		var pushed []string
+		for _, m := range manifest.Manifests {
+			pushed = append(pushed, m.Platform.OS+"/"+m.Platform.Architecture)
+		}
+		if !reflect.DeepEqual(pushed, platforms) {
+			return fmt.Errorf("Expected images for platforms %v, got %v", platforms, pushed)
+		}
+		return nil
+	}
+}
+
+func testDockerRegistryImageDigest(n string, pushOpts internalPushImageOptions) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		registryClient := testAccProvider.Meta().(*ProviderConfig).RegistryClient
+		digest, err := getImageDigestWithFallback(registryClient, pushOpts)
+		if err != nil {
+			return err
+		}
+		if digest != rs.Primary.Attributes["sha256_digest"] {
+			return fmt.Errorf("Image %s was not pushed again: digest on the registry is %s, but %s was pushed", pushOpts.Name, digest, rs.Primary.Attributes["sha256_digest"])
+		}
+		return nil
+	}
+}
+
 func testDockerRegistryImageInRegistry(pushOpts internalPushImageOptions, cleanup bool) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		providerConfig := testAccProvider.Meta().(*ProviderConfig)
-		username, password := getDockerRegistryImageRegistryUserNameAndPassword(pushOpts, providerConfig)
-		digest, err := getImageDigestWithFallback(pushOpts, username, password)
+		digest, err := getImageDigestWithFallback(providerConfig.RegistryClient, pushOpts)
 		if err != nil || len(digest) < 1 {
 			return fmt.Errorf("image not found")
 		}
 		if cleanup {
-			err := deleteDockerRegistryImage(pushOpts, digest, username, password, false)
+			err := providerConfig.RegistryClient.deleteManifest(context.Background(), pushOpts.Registry, pushOpts.Repository, digest, false)
 			if err != nil {
 				return fmt.Errorf("Unable to remove test image. %s", err)
 			}
@@ -281,6 +742,26 @@ resource "docker_registry_image" "foo" {
 }
 `
 
+const testBuildDockerRegistryImagePlatformsConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address  = 	"%s"
+	}
+}
+resource "docker_registry_image" "foo" {
+	provider = "docker.private"
+	name = "%s"
+	build {
+		context = "%s"
+		platforms = ["linux/amd64", "linux/arm64"]
+		remove = true
+		force_remove = true
+		no_cache = true
+	}
+}
+`
+
 const testDockerRegistryImagePushMissingConfig = `
 provider "docker" {
 	alias = "private"
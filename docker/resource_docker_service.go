@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
@@ -20,6 +21,12 @@ func resourceDockerService() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Read:   schema.DefaultTimeout(30 * time.Second),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
 
 		Schema: map[string]*schema.Schema{
 			"auth": {
@@ -111,6 +118,13 @@ func resourceDockerService() *schema.Resource {
 										Optional:    true,
 										Elem:        &schema.Schema{Type: schema.TypeString},
 									},
+									"sensitive_env": {
+										Type:        schema.TypeMap,
+										Description: "A map of environment variables whose values are not shown in the plan output. Must not contain keys of env",
+										Optional:    true,
+										Sensitive:   true,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+									},
 									"dir": {
 										Type:        schema.TypeString,
 										Description: "The working directory for commands to run in",
@@ -280,9 +294,11 @@ func resourceDockerService() *schema.Resource {
 													Elem: &schema.Resource{
 														Schema: map[string]*schema.Schema{
 															"size_bytes": {
-																Type:        schema.TypeInt,
-																Description: "The size for the tmpfs mount in bytes",
-																Optional:    true,
+																Type:             schema.TypeString,
+																Description:      "The size for the tmpfs mount in bytes or as size like '512m' or '2GiB'",
+																Optional:         true,
+																ValidateFunc:     validateSizeGeqThan(0),
+																DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 															},
 															"mode": {
 																Type:        schema.TypeInt,
@@ -519,9 +535,11 @@ func resourceDockerService() *schema.Resource {
 													Optional:    true,
 												},
 												"memory_bytes": {
-													Type:        schema.TypeInt,
-													Description: "The amounf of memory in bytes the container allocates",
-													Optional:    true,
+													Type:             schema.TypeString,
+													Description:      "The amounf of memory in bytes or as size like '512m' or '2GiB' the container allocates",
+													Optional:         true,
+													ValidateFunc:     validateSizeGeqThan(0),
+													DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 												},
 												"generic_resources": {
 													Type:        schema.TypeList,
@@ -563,9 +581,11 @@ func resourceDockerService() *schema.Resource {
 													Optional:    true,
 												},
 												"memory_bytes": {
-													Type:        schema.TypeInt,
-													Description: "The amounf of memory in bytes the container allocates",
-													Optional:    true,
+													Type:             schema.TypeString,
+													Description:      "The amounf of memory in bytes or as size like '512m' or '2GiB' the container allocates",
+													Optional:         true,
+													ValidateFunc:     validateSizeGeqThan(0),
+													DiffSuppressFunc: suppressIfSizesAreEqual(sizeUnitBytes),
 												},
 												"generic_resources": {
 													Type:        schema.TypeList,
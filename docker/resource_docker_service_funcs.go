@@ -18,7 +18,6 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -37,7 +36,7 @@ func resourceDockerServiceExists(d *schema.ResourceData, meta interface{}) (bool
 		return false, nil
 	}
 
-	apiService, err := fetchDockerService(d.Id(), d.Get("name").(string), client)
+	apiService, err := fetchDockerService(context.Background(), d.Id(), d.Get("name").(string), client)
 	if err != nil {
 		return false, err
 	}
@@ -51,6 +50,8 @@ func resourceDockerServiceExists(d *schema.ResourceData, meta interface{}) (bool
 func resourceDockerServiceCreate(d *schema.ResourceData, meta interface{}) error {
 	var err error
 	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
 	serviceSpec, err := createServiceSpec(d)
 	if err != nil {
@@ -63,7 +64,7 @@ func resourceDockerServiceCreate(d *schema.ResourceData, meta interface{}) error
 	serviceOptions.QueryRegistry = true
 	log.Printf("[DEBUG] Passing registry auth '%s'", serviceOptions.EncodedRegistryAuth)
 
-	service, err := client.ServiceCreate(context.Background(), serviceSpec, serviceOptions)
+	service, err := client.ServiceCreate(ctx, serviceSpec, serviceOptions)
 	if err != nil {
 		return err
 	}
@@ -71,20 +72,21 @@ func resourceDockerServiceCreate(d *schema.ResourceData, meta interface{}) error
 		convergeConfig := createConvergeConfig(v.([]interface{}))
 		log.Printf("[INFO] Waiting for Service '%s' to be created with timeout: %v", service.ID, convergeConfig.timeoutRaw)
 		timeout, _ := time.ParseDuration(convergeConfig.timeoutRaw)
-		stateConf := &resource.StateChangeConf{
-			Pending:    serviceCreatePendingStates,
-			Target:     []string{"running", "complete"},
-			Refresh:    resourceDockerServiceCreateRefreshFunc(service.ID, meta),
-			Timeout:    timeout,
-			MinTimeout: 5 * time.Second,
-			Delay:      convergeConfig.delay,
-		}
 
 		// Wait, catching any errors
-		_, err := stateConf.WaitForState()
+		err := waitForEvents(ctx, client, eventWaitConfig{
+			Filters:      eventFilters("service", service.ID),
+			Target:       []string{"running", "complete"},
+			Timeout:      timeout,
+			PollInterval: convergeConfig.delay,
+			Resync:       convergeConfig.delay,
+		}, resourceDockerServiceCreateRefreshFunc(ctx, service.ID, meta))
 		if err != nil {
-			// the service will be deleted in case it cannot be converged
-			if deleteErr := deleteService(service.ID, d, client); deleteErr != nil {
+			// the service will be deleted in case it cannot be converged,
+			// which must also work if the create timeout was exceeded
+			deleteCtx, deleteCancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+			defer deleteCancel()
+			if deleteErr := deleteService(deleteCtx, service.ID, d, client); deleteErr != nil {
 				return deleteErr
 			}
 			if strings.Contains(err.Error(), "timeout while waiting for state") {
@@ -99,44 +101,40 @@ func resourceDockerServiceCreate(d *schema.ResourceData, meta interface{}) error
 }
 
 func resourceDockerServiceRead(d *schema.ResourceData, meta interface{}) error {
-	log.Printf("[INFO] Waiting for service: '%s' to expose all fields: max '%v seconds'", d.Id(), 30)
-
-	stateConf := &resource.StateChangeConf{
-		Pending:    []string{"pending"},
-		Target:     []string{"all_fields", "removed"},
-		Refresh:    resourceDockerServiceReadRefreshFunc(d, meta),
-		Timeout:    30 * time.Second,
-		MinTimeout: 5 * time.Second,
-		Delay:      2 * time.Second,
-	}
-
-	// Wait, catching any errors
-	_, err := stateConf.WaitForState()
-	if err != nil {
-		return err
-	}
+	timeout := d.Timeout(schema.TimeoutRead)
+	log.Printf("[INFO] Waiting for service: '%s' to expose all fields: max '%v'", d.Id(), timeout)
 
-	return nil
+	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return waitForEvents(ctx, client, eventWaitConfig{
+		Filters:      eventFilters("service", d.Id()),
+		Target:       []string{"all_fields", "removed"},
+		Timeout:      timeout,
+		PollInterval: 5 * time.Second,
+		// the fields are exposed without an event of the service
+		Resync: 5 * time.Second,
+	}, resourceDockerServiceReadRefreshFunc(ctx, d, meta))
 }
 
-func resourceDockerServiceReadRefreshFunc(
-	d *schema.ResourceData, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+func resourceDockerServiceReadRefreshFunc(ctx context.Context,
+	d *schema.ResourceData, meta interface{}) eventWaitCondition {
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
 		serviceID := d.Id()
 
-		apiService, err := fetchDockerService(serviceID, d.Get("name").(string), client)
+		apiService, err := fetchDockerService(ctx, serviceID, d.Get("name").(string), client)
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 		if apiService == nil {
 			log.Printf("[WARN] Service (%s) not found, removing from state", serviceID)
 			d.SetId("")
-			return serviceID, "removed", nil
+			return true, nil
 		}
-		service, _, err := client.ServiceInspectWithRaw(context.Background(), apiService.ID, types.ServiceInspectOptions{})
+		service, _, err := client.ServiceInspectWithRaw(ctx, apiService.ID, types.ServiceInspectOptions{})
 		if err != nil {
-			return serviceID, "", fmt.Errorf("Error inspecting service %s: %s", apiService.ID, err)
+			return false, fmt.Errorf("Error inspecting service %s: %s", apiService.ID, err)
 		}
 
 		jsonObj, _ := json.MarshalIndent(service, "", "\t")
@@ -144,14 +142,14 @@ func resourceDockerServiceReadRefreshFunc(
 
 		if string(service.Endpoint.Spec.Mode) == "" && string(service.Spec.EndpointSpec.Mode) == "" {
 			log.Printf("[DEBUG] Service %s does not expose endpoint spec yet", apiService.ID)
-			return serviceID, "pending", nil
+			return false, nil
 		}
 
 		d.SetId(service.ID)
 		d.Set("name", service.Spec.Name)
 		d.Set("labels", mapToLabelSet(service.Spec.Labels))
 
-		if err = d.Set("task_spec", flattenTaskSpec(service.Spec.TaskTemplate)); err != nil {
+		if err = d.Set("task_spec", flattenTaskSpec(service.Spec.TaskTemplate, d)); err != nil {
 			log.Printf("[WARN] failed to set task spec from API: %s", err)
 		}
 		if err = d.Set("mode", flattenServiceMode(service.Spec.Mode)); err != nil {
@@ -173,17 +171,19 @@ func resourceDockerServiceReadRefreshFunc(
 				log.Printf("[WARN] failed to set endpoint spec from API: %s", err)
 			}
 		} else {
-			return serviceID, "", fmt.Errorf("Error no endpoint spec for service %s", apiService.ID)
+			return false, fmt.Errorf("Error no endpoint spec for service %s", apiService.ID)
 		}
 
-		return serviceID, "all_fields", nil
+		return true, nil
 	}
 }
 
 func resourceDockerServiceUpdate(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutUpdate))
+	defer cancel()
 
-	service, _, err := client.ServiceInspectWithRaw(context.Background(), d.Id(), types.ServiceInspectOptions{})
+	service, _, err := client.ServiceInspectWithRaw(ctx, d.Id(), types.ServiceInspectOptions{})
 	if err != nil {
 		return err
 	}
@@ -200,7 +200,7 @@ func resourceDockerServiceUpdate(d *schema.ResourceData, meta interface{}) error
 	}
 	updateOptions.EncodedRegistryAuth = base64.URLEncoding.EncodeToString(marshalledAuth)
 
-	updateResponse, err := client.ServiceUpdate(context.Background(), d.Id(), service.Version, serviceSpec, updateOptions)
+	updateResponse, err := client.ServiceUpdate(ctx, d.Id(), service.Version, serviceSpec, updateOptions)
 	if err != nil {
 		return err
 	}
@@ -212,18 +212,16 @@ func resourceDockerServiceUpdate(d *schema.ResourceData, meta interface{}) error
 		convergeConfig := createConvergeConfig(v.([]interface{}))
 		log.Printf("[INFO] Waiting for Service '%s' to be updated with timeout: %v", service.ID, convergeConfig.timeoutRaw)
 		timeout, _ := time.ParseDuration(convergeConfig.timeoutRaw)
-		stateConf := &resource.StateChangeConf{
-			Pending:    serviceUpdatePendingStates,
-			Target:     []string{"completed"},
-			Refresh:    resourceDockerServiceUpdateRefreshFunc(service.ID, meta),
-			Timeout:    timeout,
-			MinTimeout: 5 * time.Second,
-			Delay:      7 * time.Second,
-		}
 
 		// Wait, catching any errors
-		state, err := stateConf.WaitForState()
-		log.Printf("[INFO] State awaited: %v with error: %v", state, err)
+		err := waitForEvents(ctx, client, eventWaitConfig{
+			Filters:      eventFilters("service", service.ID, "update"),
+			Target:       []string{"completed"},
+			Timeout:      timeout,
+			PollInterval: convergeConfig.delay,
+			Resync:       convergeConfig.delay,
+		}, resourceDockerServiceUpdateRefreshFunc(ctx, service.ID, service.UpdateStatus, meta))
+		log.Printf("[INFO] Service update awaited with error: %v", err)
 		if err != nil {
 			if strings.Contains(err.Error(), "timeout while waiting for state") {
 				return &DidNotConvergeError{ServiceID: service.ID, Timeout: convergeConfig.timeout}
@@ -237,8 +235,10 @@ func resourceDockerServiceUpdate(d *schema.ResourceData, meta interface{}) error
 
 func resourceDockerServiceDelete(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
 
-	if err := deleteService(d.Id(), d, client); err != nil {
+	if err := deleteService(ctx, d.Id(), d, client); err != nil {
 		return err
 	}
 
@@ -250,8 +250,8 @@ func resourceDockerServiceDelete(d *schema.ResourceData, meta interface{}) error
 // Helpers
 /////////////////
 // fetchDockerService fetches a service by its name or id
-func fetchDockerService(ID string, name string, client *client.Client) (*swarm.Service, error) {
-	apiServices, err := client.ServiceList(context.Background(), types.ServiceListOptions{})
+func fetchDockerService(ctx context.Context, ID string, name string, client *client.Client) (*swarm.Service, error) {
+	apiServices, err := client.ServiceList(ctx, types.ServiceListOptions{})
 
 	if err != nil {
 		return nil, fmt.Errorf("Error fetching service information from Docker: %s", err)
@@ -267,20 +267,20 @@ func fetchDockerService(ID string, name string, client *client.Client) (*swarm.S
 }
 
 // deleteService deletes the service with the given id
-func deleteService(serviceID string, d *schema.ResourceData, client *client.Client) error {
+func deleteService(ctx context.Context, serviceID string, d *schema.ResourceData, client *client.Client) error {
 	// get containerIDs of the running service because they do not exist after the service is deleted
 	serviceContainerIds := make([]string, 0)
 	if _, ok := d.GetOk("task_spec.0.container_spec.0.stop_grace_period"); ok {
 		filters := filters.NewArgs()
 		filters.Add("service", d.Get("name").(string))
-		tasks, err := client.TaskList(context.Background(), types.TaskListOptions{
+		tasks, err := client.TaskList(ctx, types.TaskListOptions{
 			Filters: filters,
 		})
 		if err != nil {
 			return err
 		}
 		for _, t := range tasks {
-			task, _, _ := client.TaskInspectWithRaw(context.Background(), t.ID)
+			task, _, _ := client.TaskInspectWithRaw(ctx, t.ID)
 			containerID := ""
 			if task.Status.ContainerStatus != nil {
 				containerID = task.Status.ContainerStatus.ContainerID
@@ -294,7 +294,7 @@ func deleteService(serviceID string, d *schema.ResourceData, client *client.Clie
 
 	// delete the service
 	log.Printf("[INFO] Deleting service: '%s'", serviceID)
-	if err := client.ServiceRemove(context.Background(), serviceID); err != nil {
+	if err := client.ServiceRemove(ctx, serviceID); err != nil {
 		return fmt.Errorf("Error deleting service %s: %s", serviceID, err)
 	}
 
@@ -303,10 +303,10 @@ func deleteService(serviceID string, d *schema.ResourceData, client *client.Clie
 		for _, containerID := range serviceContainerIds {
 			destroyGraceSeconds, _ := time.ParseDuration(v.(string))
 			log.Printf("[INFO] Waiting for container: '%s' to exit: max %v", containerID, destroyGraceSeconds)
-			ctx, cancel := context.WithTimeout(context.Background(), destroyGraceSeconds)
+			waitCtx, cancel := context.WithTimeout(ctx, destroyGraceSeconds)
 			// TODO why defer? see container_resource with handling return channels! why not remove then wait?
 			defer cancel()
-			exitCode, _ := client.ContainerWait(ctx, containerID, container.WaitConditionRemoved)
+			exitCode, _ := client.ContainerWait(waitCtx, containerID, container.WaitConditionRemoved)
 			log.Printf("[INFO] Container exited with code [%v]: '%s'", exitCode, containerID)
 
 			removeOpts := types.ContainerRemoveOptions{
@@ -315,7 +315,7 @@ func deleteService(serviceID string, d *schema.ResourceData, client *client.Clie
 			}
 
 			log.Printf("[INFO] Removing container: '%s'", containerID)
-			if err := client.ContainerRemove(context.Background(), containerID, removeOpts); err != nil {
+			if err := client.ContainerRemove(ctx, containerID, removeOpts); err != nil {
 				if !(strings.Contains(err.Error(), "No such container") || strings.Contains(err.Error(), "is already in progress")) {
 					return fmt.Errorf("Error deleting container %s: %s", containerID, err)
 				}
@@ -344,12 +344,11 @@ func (err *DidNotConvergeError) Error() string {
 	return "Service with ID (" + err.ServiceID + ") did not converge after " + err.Timeout.String()
 }
 
-// resourceDockerServiceCreateRefreshFunc refreshes the state of a service when it is created and needs to converge
-func resourceDockerServiceCreateRefreshFunc(
-	serviceID string, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+// resourceDockerServiceCreateRefreshFunc checks if a service converged after it was created
+func resourceDockerServiceCreateRefreshFunc(ctx context.Context,
+	serviceID string, meta interface{}) eventWaitCondition {
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
-		ctx := context.Background()
 
 		var updater progressUpdater
 
@@ -369,38 +368,40 @@ func resourceDockerServiceCreateRefreshFunc(
 
 		service, _, err := client.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		tasks, err := getUpToDateTasks()
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		activeNodes, err := getActiveNodes(ctx, client)
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		serviceCreateStatus, err := updater.update(&service, tasks, activeNodes, false)
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		if serviceCreateStatus {
-			return service.ID, "running", nil
+			return true, nil
 		}
 
-		return service.ID, "creating", nil
+		return false, nil
 	}
 }
 
-// resourceDockerServiceUpdateRefreshFunc refreshes the state of a service when it is updated and needs to converge
-func resourceDockerServiceUpdateRefreshFunc(
-	serviceID string, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+// resourceDockerServiceUpdateRefreshFunc checks if a service converged after it was updated.
+// The update status of the previous update is ignored, and as long as no new rollout was started
+// the tasks are only checked after a grace period, because they might not be replaced yet.
+func resourceDockerServiceUpdateRefreshFunc(ctx context.Context,
+	serviceID string, previousUpdateStatus *swarm.UpdateStatus, meta interface{}) eventWaitCondition {
+	updatedAt := time.Now()
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
-		ctx := context.Background()
 
 		var (
 			updater  progressUpdater
@@ -424,53 +425,69 @@ func resourceDockerServiceUpdateRefreshFunc(
 
 		service, _, err := client.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
-		if service.UpdateStatus != nil {
+		if isSameUpdateStatus(service.UpdateStatus, previousUpdateStatus) {
+			if time.Since(updatedAt) < serviceUpdateGracePeriod {
+				log.Printf("[DEBUG] rollout of service %s not started yet", serviceID)
+				return false, nil
+			}
+		} else if service.UpdateStatus != nil {
 			log.Printf("[DEBUG] update status: %v", service.UpdateStatus.State)
 			switch service.UpdateStatus.State {
 			case swarm.UpdateStateUpdating:
 				rollback = false
 			case swarm.UpdateStateCompleted:
-				return service.ID, "completed", nil
+				return true, nil
 			case swarm.UpdateStateRollbackStarted:
 				rollback = true
 			case swarm.UpdateStateRollbackCompleted:
-				return nil, "", fmt.Errorf("service rollback completed: %s", service.UpdateStatus.Message)
+				return false, fmt.Errorf("service rollback completed: %s", service.UpdateStatus.Message)
 			case swarm.UpdateStatePaused:
-				return nil, "", fmt.Errorf("service update paused: %s", service.UpdateStatus.Message)
+				return false, fmt.Errorf("service update paused: %s", service.UpdateStatus.Message)
 			case swarm.UpdateStateRollbackPaused:
-				return nil, "", fmt.Errorf("service rollback paused: %s", service.UpdateStatus.Message)
+				return false, fmt.Errorf("service rollback paused: %s", service.UpdateStatus.Message)
 			}
 		}
 
 		tasks, err := getUpToDateTasks()
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		activeNodes, err := getActiveNodes(ctx, client)
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		isUpdateCompleted, err := updater.update(&service, tasks, activeNodes, rollback)
 		if err != nil {
-			return nil, "", err
+			return false, err
 		}
 
 		if isUpdateCompleted {
 			if rollback {
-				return nil, "", fmt.Errorf("service rollback completed: %s", service.UpdateStatus.Message)
+				return false, fmt.Errorf("service rollback completed: %s", service.UpdateStatus.Message)
 			}
-			return service.ID, "completed", nil
+			return true, nil
 		}
 
-		return service.ID, "updating", nil
+		return false, nil
 	}
 }
 
+// isSameUpdateStatus checks if both update status belong to the same rollout
+func isSameUpdateStatus(a, b *swarm.UpdateStatus) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.StartedAt == nil || b.StartedAt == nil {
+		return a.StartedAt == b.StartedAt
+	}
+	return a.StartedAt.Equal(*b.StartedAt)
+}
+
 // getActiveNodes gets the actives nodes withon a swarm
 func getActiveNodes(ctx context.Context, client *client.Client) (map[string]struct{}, error) {
 	nodes, err := client.NodeList(ctx, types.NodeListOptions{})
@@ -741,6 +758,13 @@ func createContainerSpec(v interface{}) (*swarm.ContainerSpec, error) {
 			if value, ok := rawContainerSpec["env"]; ok {
 				containerSpec.Env = mapTypeMapValsToStringSlice(value.(map[string]interface{}))
 			}
+			if value, ok := rawContainerSpec["sensitive_env"]; ok {
+				sensitiveEnv := value.(map[string]interface{})
+				if err := validateSensitiveEnv(containerSpec.Env, sensitiveEnv); err != nil {
+					return nil, err
+				}
+				containerSpec.Env = append(containerSpec.Env, mapTypeMapValsToStringSlice(sensitiveEnv)...)
+			}
 			if value, ok := rawContainerSpec["dir"]; ok {
 				containerSpec.Dir = value.(string)
 			}
@@ -862,7 +886,11 @@ func createContainerSpec(v interface{}) (*swarm.ContainerSpec, error) {
 								for _, rawTmpfsOptions := range value.([]interface{}) {
 									rawTmpfsOptions := rawTmpfsOptions.(map[string]interface{})
 									if value, ok := rawTmpfsOptions["size_bytes"]; ok {
-										mountInstance.TmpfsOptions.SizeBytes = value.(int64)
+										sizeBytes, err := parseSize(value.(string), sizeUnitBytes)
+										if err != nil {
+											return nil, fmt.Errorf("Unable to parse tmpfs size_bytes of mount %s: %s", mountInstance.Target, err)
+										}
+										mountInstance.TmpfsOptions.SizeBytes = sizeBytes
 									}
 									if value, ok := rawTmpfsOptions["mode"]; ok {
 										mountInstance.TmpfsOptions.Mode = os.FileMode(value.(int))
@@ -998,7 +1026,11 @@ func createResources(v interface{}) (*swarm.ResourceRequirements, error) {
 								resources.Limits.NanoCPUs = int64(value.(int))
 							}
 							if value, ok := rawLimitsSpec["memory_bytes"]; ok {
-								resources.Limits.MemoryBytes = int64(value.(int))
+								memoryBytes, err := parseSize(value.(string), sizeUnitBytes)
+								if err != nil {
+									return nil, fmt.Errorf("Unable to parse memory_bytes: %s", err)
+								}
+								resources.Limits.MemoryBytes = memoryBytes
 							}
 							if value, ok := rawLimitsSpec["generic_resources"]; ok {
 								resources.Limits.GenericResources, _ = createGenericResources(value)
@@ -1015,7 +1047,11 @@ func createResources(v interface{}) (*swarm.ResourceRequirements, error) {
 								resources.Reservations.NanoCPUs = int64(value.(int))
 							}
 							if value, ok := rawReservationSpec["memory_bytes"]; ok {
-								resources.Reservations.MemoryBytes = int64(value.(int))
+								memoryBytes, err := parseSize(value.(string), sizeUnitBytes)
+								if err != nil {
+									return nil, fmt.Errorf("Unable to parse memory_bytes: %s", err)
+								}
+								resources.Reservations.MemoryBytes = memoryBytes
 							}
 							if value, ok := rawReservationSpec["generic_resources"]; ok {
 								resources.Reservations.GenericResources, _ = createGenericResources(value)
@@ -1389,6 +1425,10 @@ func mapSetToPlacementPlatforms(stringSet *schema.Set) []swarm.Platform {
 
 //////// States
 
+// serviceUpdateGracePeriod is the time an update of a service gets to start its
+// rollout before the tasks are checked for convergence
+const serviceUpdateGracePeriod = 7 * time.Second
+
 // numberedStates are ascending sorted states for docker tasks
 // meaning they appear internally in this order in the statemachine
 var (
@@ -1413,23 +1453,3 @@ var (
 
 	longestState int
 )
-
-// serviceCreatePendingStates are the pending states for the creation of a service
-var serviceCreatePendingStates = []string{
-	"new",
-	"allocated",
-	"pending",
-	"assigned",
-	"accepted",
-	"preparing",
-	"ready",
-	"starting",
-	"creating",
-	"paused",
-}
-
-// serviceUpdatePendingStates are the pending states for the update of a service
-var serviceUpdatePendingStates = []string{
-	"creating",
-	"updating",
-}
@@ -247,6 +247,10 @@ func TestAccDockerService_fullSpec(t *testing.T) {
 								URI   = "/api-call?param1=value1"
 							}
 
+							sensitive_env = {
+								DB_PASSWORD = "secret"
+							}
+
 							dir    = "/root"
 							user   = "root"
 							groups = ["docker", "foogroup"]
@@ -412,6 +416,8 @@ func TestAccDockerService_fullSpec(t *testing.T) {
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.hostname", "my-fancy-service"),
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.env.MYFOO", "BAR"),
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.env.URI", "/api-call?param1=value1"),
+					resource.TestCheckNoResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.env.DB_PASSWORD"),
+					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.sensitive_env.DB_PASSWORD", "secret"),
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.dir", "/root"),
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.user", "root"),
 					resource.TestCheckResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.groups.0", "docker"),
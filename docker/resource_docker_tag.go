@@ -0,0 +1,187 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceDockerTag() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerTagCreate,
+		Read:   resourceDockerTagRead,
+		Update: resourceDockerTagUpdate,
+		Delete: resourceDockerTagDelete,
+
+		CustomizeDiff: resourceDockerTagCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"source_image": {
+				Type:        schema.TypeString,
+				Description: "The name, ID or digest of the local image to tag",
+				Required:    true,
+			},
+
+			"target_image": {
+				Type:        schema.TypeString,
+				Description: "The tag to create, e.g. 'registry.local/nginx:prod'",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"force_remove": {
+				Type:        schema.TypeBool,
+				Description: "Remove the tag on destroy even if it is the last reference of the image, which removes the image as well",
+				Optional:    true,
+				Default:     false,
+			},
+
+			"source_image_id": {
+				Type:        schema.TypeString,
+				Description: "The ID of the image the tag points to",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceDockerTagCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := tagImage(d, meta); err != nil {
+		return err
+	}
+	d.SetId(d.Get("target_image").(string))
+	return nil
+}
+
+func resourceDockerTagRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+
+	// the tag is read from the target, so a moved tag is a diff of source_image_id
+	targetImage := d.Get("target_image").(string)
+	imageID, err := resolveImageID(context.Background(), client, targetImage)
+	if err != nil {
+		return err
+	}
+	if imageID == "" {
+		log.Printf("[WARN] Tag %s not found, removing from state", targetImage)
+		d.SetId("")
+		return nil
+	}
+	d.Set("source_image_id", imageID)
+	return nil
+}
+
+func resourceDockerTagUpdate(d *schema.ResourceData, meta interface{}) error {
+	return tagImage(d, meta)
+}
+
+func resourceDockerTagDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
+
+	targetImage := d.Get("target_image").(string)
+	image, _, err := client.ImageInspectWithRaw(ctx, targetImage)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such image") {
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("Unable to inspect image %s: %s", targetImage, err)
+	}
+
+	// Removing the last reference of an image removes the image itself,
+	// which is not managed by this resource.
+	if imageReferencesKeptByOthers(image.RepoTags, image.RepoDigests, targetImage) == 0 && !d.Get("force_remove").(bool) {
+		return fmt.Errorf("Unable to remove tag %s: it is the last reference of image %s, which would be removed as well. Set force_remove to remove the tag and the image", targetImage, image.ID)
+	}
+
+	log.Printf("[DEBUG] Removing tag %s of image %s", targetImage, image.ID)
+	if _, err := client.ImageRemove(ctx, targetImage, types.ImageRemoveOptions{}); err != nil {
+		if !strings.Contains(err.Error(), "No such image") {
+			return fmt.Errorf("Unable to remove tag %s: %s", targetImage, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// resourceDockerTagCustomizeDiff moves the tag if the source image
+// points to another image than the tag
+func resourceDockerTagCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.NewValueKnown("source_image") {
+		return nil
+	}
+
+	client := meta.(*ProviderConfig).DockerClient
+	sourceImage := d.Get("source_image").(string)
+	imageID, err := resolveImageID(context.Background(), client, sourceImage)
+	if err != nil {
+		return err
+	}
+	if imageID == "" {
+		// the missing image is reported on apply
+		return d.SetNewComputed("source_image_id")
+	}
+	if imageID != d.Get("source_image_id").(string) {
+		log.Printf("[DEBUG] Source image %s moved from %s to %s", sourceImage, d.Get("source_image_id").(string), imageID)
+		return d.SetNew("source_image_id", imageID)
+	}
+	return nil
+}
+
+// tagImage tags the source image with the target image
+// and records the ID of the tagged image
+func tagImage(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
+
+	sourceImage := d.Get("source_image").(string)
+	targetImage := d.Get("target_image").(string)
+	imageID, err := resolveImageID(ctx, client, sourceImage)
+	if err != nil {
+		return err
+	}
+	if imageID == "" {
+		return fmt.Errorf("Unable to tag image %s: image not found", sourceImage)
+	}
+
+	log.Printf("[DEBUG] Tagging image %s (%s) as %s", sourceImage, imageID, targetImage)
+	if err := client.ImageTag(ctx, imageID, targetImage); err != nil {
+		return fmt.Errorf("Unable to tag image %s as %s: %s", sourceImage, targetImage, err)
+	}
+
+	d.Set("source_image_id", imageID)
+	return nil
+}
+
+// imageReferencesKeptByOthers returns the number of references which keep the image
+// once the tag is removed. Removing the last tag of a repository also removes the
+// digest references of the repository, so only the digests of other repositories count.
+func imageReferencesKeptByOthers(repoTags []string, repoDigests []string, tag string) int {
+	named, err := reference.ParseNormalizedNamed(tag)
+	if err != nil {
+		return 0
+	}
+	named = reference.TagNameOnly(named)
+
+	references := 0
+	for _, repoTag := range repoTags {
+		other, err := reference.ParseNormalizedNamed(repoTag)
+		if err == nil && other.String() != named.String() {
+			references++
+		}
+	}
+	for _, repoDigest := range repoDigests {
+		other, err := reference.ParseNormalizedNamed(repoDigest)
+		if err == nil && other.Name() != named.Name() {
+			references++
+		}
+	}
+	return references
+}
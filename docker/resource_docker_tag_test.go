@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccDockerTag_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerTagDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerTagConfig, "alpine:3.11"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("docker_tag.foo", "id", "tftest/alpine:prod"),
+					resource.TestCheckResourceAttrPair("docker_tag.foo", "source_image_id", "docker_image.alpine311", "latest"),
+					testAccCheckDockerTag("tftest/alpine:prod", "docker_image.alpine311"),
+				),
+			},
+			{
+				// the moved source retags the target
+				Config: fmt.Sprintf(testAccDockerTagConfig, "alpine:3.12"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("docker_tag.foo", "source_image_id", "docker_image.alpine312", "latest"),
+					testAccCheckDockerTag("tftest/alpine:prod", "docker_image.alpine312"),
+				),
+			},
+		},
+	})
+}
+
+func TestImageReferencesKeptByOthers(t *testing.T) {
+	digest := "@sha256:9a839e63dad54c3a6d1834e29692c8492d93f90c59c978c1ed79109ea4fb9a54"
+	cases := []struct {
+		repoTags    []string
+		repoDigests []string
+		tag         string
+		expected    int
+	}{
+		{repoTags: []string{"nginx:prod"}, tag: "nginx:prod", expected: 0},
+		{repoTags: []string{"nginx:prod", "nginx:1.19"}, tag: "nginx:prod", expected: 1},
+		// the digest of the repository is removed with its last tag
+		{repoTags: []string{"nginx:prod"}, repoDigests: []string{"nginx" + digest}, tag: "nginx:prod", expected: 0},
+		{repoTags: []string{"nginx:prod"}, repoDigests: []string{"nginx" + digest}, tag: "docker.io/library/nginx:prod", expected: 0},
+		{repoTags: []string{"app:prod"}, repoDigests: []string{"nginx" + digest}, tag: "app:prod", expected: 1},
+		{repoTags: []string{"app:latest", "nginx:latest"}, tag: "app", expected: 1},
+	}
+	for _, c := range cases {
+		if references := imageReferencesKeptByOthers(c.repoTags, c.repoDigests, c.tag); references != c.expected {
+			t.Fatalf("Expected %d references keeping the image of %s (tags %v, digests %v), got %d",
+				c.expected, c.tag, c.repoTags, c.repoDigests, references)
+		}
+	}
+}
+
+func testAccCheckDockerTag(tag string, image string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[image]
+		if !ok {
+			return fmt.Errorf("Not found: %s", image)
+		}
+
+		client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+		inspect, _, err := client.ImageInspectWithRaw(context.Background(), tag)
+		if err != nil {
+			return err
+		}
+		if inspect.ID != rs.Primary.Attributes["latest"] {
+			return fmt.Errorf("Tag %s points to %s instead of %s", tag, inspect.ID, rs.Primary.Attributes["latest"])
+		}
+		return nil
+	}
+}
+
+func testAccDockerTagDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "docker_tag" {
+			continue
+		}
+
+		_, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["target_image"])
+		if err == nil {
+			return fmt.Errorf("Tag still exists: %s", rs.Primary.Attributes["target_image"])
+		}
+		if !strings.Contains(err.Error(), "No such image") {
+			return err
+		}
+	}
+	return nil
+}
+
+const testAccDockerTagConfig = `
+resource "docker_image" "alpine311" {
+	name = "alpine:3.11"
+	keep_locally = true
+}
+
+resource "docker_image" "alpine312" {
+	name = "alpine:3.12"
+	keep_locally = true
+}
+
+resource "docker_tag" "foo" {
+	source_image = "%s"
+	target_image = "tftest/alpine:prod"
+	depends_on = ["docker_image.alpine311", "docker_image.alpine312"]
+}
+`
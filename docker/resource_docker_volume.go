@@ -9,7 +9,6 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/volume"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -21,6 +20,10 @@ func resourceDockerVolume() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Second),
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -101,7 +104,8 @@ func resourceDockerVolumeV0() *schema.Resource {
 
 func resourceDockerVolumeCreate(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
 	createOpts := volume.VolumeCreateBody{}
 
@@ -152,19 +156,19 @@ func resourceDockerVolumeRead(d *schema.ResourceData, meta interface{}) error {
 }
 
 func resourceDockerVolumeDelete(d *schema.ResourceData, meta interface{}) error {
-	log.Printf("[INFO] Waiting for volume: '%s' to get removed: max '%v seconds'", d.Id(), 30)
-
-	stateConf := &resource.StateChangeConf{
-		Pending:    []string{"in_use"},
-		Target:     []string{"removed"},
-		Refresh:    resourceDockerVolumeRemoveRefreshFunc(d.Id(), meta),
-		Timeout:    30 * time.Second,
-		MinTimeout: 5 * time.Second,
-		Delay:      2 * time.Second,
-	}
-
-	// Wait, catching any errors
-	_, err := stateConf.WaitForState()
+	client := meta.(*ProviderConfig).DockerClient
+	timeout := d.Timeout(schema.TimeoutDelete)
+	log.Printf("[INFO] Waiting for volume: '%s' to get removed: max '%v'", d.Id(), timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// a volume in use gets released when the container using it is destroyed
+	err := waitForEvents(ctx, client, eventWaitConfig{
+		Filters:      eventFilters("container", "", "destroy"),
+		Target:       []string{"removed"},
+		Timeout:      timeout,
+		PollInterval: 5 * time.Second,
+	}, resourceDockerVolumeRemoveFunc(ctx, d.Id(), meta))
 	if err != nil {
 		return err
 	}
@@ -173,21 +177,21 @@ func resourceDockerVolumeDelete(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
-func resourceDockerVolumeRemoveRefreshFunc(
-	volumeID string, meta interface{}) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
+func resourceDockerVolumeRemoveFunc(ctx context.Context,
+	volumeID string, meta interface{}) eventWaitCondition {
+	return func() (bool, error) {
 		client := meta.(*ProviderConfig).DockerClient
 		forceDelete := true
 
-		if err := client.VolumeRemove(context.Background(), volumeID, forceDelete); err != nil {
+		if err := client.VolumeRemove(ctx, volumeID, forceDelete); err != nil {
 			if strings.Contains(err.Error(), "volume is in use") { // store.IsInUse(err)
 				log.Printf("[INFO] Volume with id '%v' is still in use", volumeID)
-				return volumeID, "in_use", nil
+				return false, nil
 			}
 			log.Printf("[INFO] Removing volume with id '%v' caused an error: %v", volumeID, err)
-			return nil, "", err
+			return false, err
 		}
 		log.Printf("[INFO] Removing volume with id '%v' got removed", volumeID)
-		return volumeID, "removed", nil
+		return true, nil
 	}
 }
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"strconv"
+
+	"github.com/docker/go-units"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+const (
+	sizeUnitBytes     int64 = 1
+	sizeUnitMegabytes int64 = 1024 * 1024
+)
+
+// parseSize parses a size which is either given as plain number in the unit of
+// the attribute, e.g. '512' for 512MB of container memory, or as human
+// readable size like '512m' or '2GiB'. It returns the size in bytes.
+// An empty size is 0 and negative plain numbers, e.g. -1 for unlimited,
+// are returned unchanged.
+func parseSize(size string, unit int64) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+		if n > 0 {
+			return n * unit, nil
+		}
+		return n, nil
+	}
+	return units.RAMInBytes(size)
+}
+
+// flattenSize returns the configured size if it equals the given size in bytes
+// so the human readable form stays in the state. Otherwise it returns the size
+// as plain number in the unit of the attribute or an empty string if it is 0.
+func flattenSize(configured string, size int64, unit int64) string {
+	if configuredSize, err := parseSize(configured, unit); err == nil && configuredSize == size {
+		return configured
+	}
+	if size > 0 {
+		size = size / unit
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// suppressIfSizesAreEqual suppresses the diff if both sizes are the same
+// number of bytes, e.g. '512' and '512m' for a size in MB
+func suppressIfSizesAreEqual(unit int64) schema.SchemaDiffSuppressFunc {
+	return func(k, old, new string, d *schema.ResourceData) bool {
+		oldSize, err := parseSize(old, unit)
+		if err != nil {
+			return false
+		}
+		newSize, err := parseSize(new, unit)
+		if err != nil {
+			return false
+		}
+		return oldSize == newSize
+	}
+}
+
+// configuredTmpfsSizes returns the configured tmpfs sizes of the
+// given mounts by their target
+func configuredTmpfsSizes(mounts *schema.Set) map[string]string {
+	sizes := make(map[string]string)
+	for _, rawMount := range mounts.List() {
+		rawMount := rawMount.(map[string]interface{})
+		for _, rawTmpfsOptions := range rawMount["tmpfs_options"].([]interface{}) {
+			if rawTmpfsOptions == nil {
+				continue
+			}
+			sizes[rawMount["target"].(string)] = rawTmpfsOptions.(map[string]interface{})["size_bytes"].(string)
+		}
+	}
+	return sizes
+}
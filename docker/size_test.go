@@ -0,0 +1,70 @@
+package docker
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		size     string
+		unit     int64
+		expected int64
+	}{
+		{"", sizeUnitMegabytes, 0},
+		{"0", sizeUnitMegabytes, 0},
+		{"-1", sizeUnitMegabytes, -1},
+		{"512", sizeUnitMegabytes, 512 * 1024 * 1024},
+		{"512", sizeUnitBytes, 512},
+		{"512m", sizeUnitMegabytes, 512 * 1024 * 1024},
+		{"512m", sizeUnitBytes, 512 * 1024 * 1024},
+		{"2GiB", sizeUnitBytes, 2 * 1024 * 1024 * 1024},
+		{"64k", sizeUnitBytes, 64 * 1024},
+	}
+	for _, c := range cases {
+		size, err := parseSize(c.size, c.unit)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", c.size, err)
+		}
+		if size != c.expected {
+			t.Fatalf("Expected %q to be %d bytes, got %d", c.size, c.expected, size)
+		}
+	}
+
+	if _, err := parseSize("foo", sizeUnitBytes); err == nil {
+		t.Fatal("Expected an error for an invalid size")
+	}
+}
+
+func TestFlattenSize(t *testing.T) {
+	cases := []struct {
+		configured string
+		size       int64
+		unit       int64
+		expected   string
+	}{
+		{"", 0, sizeUnitMegabytes, ""},
+		{"", 64 * 1024 * 1024, sizeUnitMegabytes, "64"},
+		{"512m", 512 * 1024 * 1024, sizeUnitMegabytes, "512m"},
+		{"512", 512 * 1024 * 1024, sizeUnitMegabytes, "512"},
+		{"512m", 1024 * 1024 * 1024, sizeUnitMegabytes, "1024"},
+		{"-1", -1, sizeUnitMegabytes, "-1"},
+		{"1GiB", 1024 * 1024 * 1024, sizeUnitBytes, "1GiB"},
+		{"1GiB", 1024, sizeUnitBytes, "1024"},
+	}
+	for _, c := range cases {
+		if flattened := flattenSize(c.configured, c.size, c.unit); flattened != c.expected {
+			t.Fatalf("Expected %q with %d bytes to be flattened to %q, got %q", c.configured, c.size, c.expected, flattened)
+		}
+	}
+}
+
+func TestSuppressIfSizesAreEqual(t *testing.T) {
+	suppress := suppressIfSizesAreEqual(sizeUnitMegabytes)
+	if !suppress("memory", "512", "512m", nil) {
+		t.Fatal("Expected the diff between 512 and 512m to be suppressed")
+	}
+	if !suppress("memory", "1024", "1GiB", nil) {
+		t.Fatal("Expected the diff between 1024 and 1GiB to be suppressed")
+	}
+	if suppress("memory", "512", "1g", nil) {
+		t.Fatal("Expected the diff between 512 and 1g not to be suppressed")
+	}
+}
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -11,13 +12,19 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
-func flattenTaskSpec(in swarm.TaskSpec) []interface{} {
+// flattenTaskSpec flattens the task spec. The configuration in d is used to
+// keep the sensitive env vars out of env and the sizes in their configured form.
+func flattenTaskSpec(in swarm.TaskSpec, d *schema.ResourceData) []interface{} {
 	m := make(map[string]interface{})
 	if in.ContainerSpec != nil {
-		m["container_spec"] = flattenContainerSpec(in.ContainerSpec)
+		m["container_spec"] = flattenContainerSpec(in.ContainerSpec,
+			d.Get("task_spec.0.container_spec.0.sensitive_env").(map[string]interface{}),
+			configuredTmpfsSizes(d.Get("task_spec.0.container_spec.0.mounts").(*schema.Set)))
 	}
 	if in.Resources != nil {
-		m["resources"] = flattenTaskResources(in.Resources)
+		m["resources"] = flattenTaskResources(in.Resources,
+			d.Get("task_spec.0.resources.0.limits.0.memory_bytes").(string),
+			d.Get("task_spec.0.resources.0.reservation.0.memory_bytes").(string))
 	}
 	if in.RestartPolicy != nil {
 		m["restart_policy"] = flattenTaskRestartPolicy(in.RestartPolicy)
@@ -106,7 +113,7 @@ func flattenServiceEndpointSpec(in *swarm.EndpointSpec) []interface{} {
 }
 
 ///// start TaskSpec
-func flattenContainerSpec(in *swarm.ContainerSpec) []interface{} {
+func flattenContainerSpec(in *swarm.ContainerSpec, sensitiveEnv map[string]interface{}, tmpfsSizes map[string]string) []interface{} {
 	var out = make([]interface{}, 0, 0)
 	m := make(map[string]interface{})
 	if len(in.Image) > 0 {
@@ -125,7 +132,13 @@ func flattenContainerSpec(in *swarm.ContainerSpec) []interface{} {
 		m["hostname"] = in.Hostname
 	}
 	if len(in.Env) > 0 {
-		m["env"] = mapStringSliceToMap(in.Env)
+		env, sensitive := splitSensitiveEnv(mapStringSliceToMap(in.Env), sensitiveEnv)
+		if len(env) > 0 {
+			m["env"] = env
+		}
+		if len(sensitive) > 0 {
+			m["sensitive_env"] = sensitive
+		}
 	}
 	if len(in.User) > 0 {
 		m["user"] = in.User
@@ -143,7 +156,7 @@ func flattenContainerSpec(in *swarm.ContainerSpec) []interface{} {
 		m["read_only"] = in.ReadOnly
 	}
 	if len(in.Mounts) > 0 {
-		m["mounts"] = flattenServiceMounts(in.Mounts)
+		m["mounts"] = flattenServiceMounts(in.Mounts, tmpfsSizes)
 	}
 	if len(in.StopSignal) > 0 {
 		m["stop_signal"] = in.StopSignal
@@ -204,7 +217,7 @@ func flattenPrivileges(in *swarm.Privileges) []interface{} {
 	return out
 }
 
-func flattenServiceMounts(in []mount.Mount) *schema.Set {
+func flattenServiceMounts(in []mount.Mount, tmpfsSizes map[string]string) *schema.Set {
 	var out = make([]interface{}, len(in), len(in))
 	for i, v := range in {
 		m := make(map[string]interface{})
@@ -245,7 +258,7 @@ func flattenServiceMounts(in []mount.Mount) *schema.Set {
 			tmpfsOptions := make([]interface{}, 0, 0)
 			tmpfsOptionsItem := make(map[string]interface{}, 0)
 
-			tmpfsOptionsItem["size_bytes"] = int(v.TmpfsOptions.SizeBytes)
+			tmpfsOptionsItem["size_bytes"] = flattenSize(tmpfsSizes[v.Target], v.TmpfsOptions.SizeBytes, sizeUnitBytes)
 			tmpfsOptionsItem["mode"] = v.TmpfsOptions.Mode.Perm
 
 			tmpfsOptions = append(tmpfsOptions, tmpfsOptionsItem)
@@ -369,23 +382,23 @@ func flattenServiceConfigs(in []*swarm.ConfigReference) *schema.Set {
 	return schema.NewSet(f, out)
 }
 
-func flattenTaskResources(in *swarm.ResourceRequirements) []interface{} {
+func flattenTaskResources(in *swarm.ResourceRequirements, limitsMemory, reservationMemory string) []interface{} {
 	var out = make([]interface{}, 0, 0)
 	if in != nil {
 		m := make(map[string]interface{})
-		m["limits"] = flattenResourceLimitsOrReservations(in.Limits)
-		m["reservation"] = flattenResourceLimitsOrReservations(in.Reservations)
+		m["limits"] = flattenResourceLimitsOrReservations(in.Limits, limitsMemory)
+		m["reservation"] = flattenResourceLimitsOrReservations(in.Reservations, reservationMemory)
 		out = append(out, m)
 	}
 	return out
 }
 
-func flattenResourceLimitsOrReservations(in *swarm.Resources) []interface{} {
+func flattenResourceLimitsOrReservations(in *swarm.Resources, memory string) []interface{} {
 	var out = make([]interface{}, 0, 0)
 	if in != nil {
 		m := make(map[string]interface{})
 		m["nano_cpus"] = in.NanoCPUs
-		m["memory_bytes"] = in.MemoryBytes
+		m["memory_bytes"] = flattenSize(memory, in.MemoryBytes, sizeUnitBytes)
 		m["generic_resources"] = flattenResourceGenericResource(in.GenericResources)
 		out = append(out, m)
 	}
@@ -553,6 +566,32 @@ func mapStringSliceToMap(in []string) map[string]string {
 	return mapped
 }
 
+// splitSensitiveEnv splits the environment variables into the plain ones and
+// the ones whose keys are configured as sensitive
+func splitSensitiveEnv(env map[string]string, sensitiveEnv map[string]interface{}) (map[string]string, map[string]string) {
+	plain := make(map[string]string, len(env))
+	sensitive := make(map[string]string, len(sensitiveEnv))
+	for k, v := range env {
+		if _, ok := sensitiveEnv[k]; ok {
+			sensitive[k] = v
+			continue
+		}
+		plain[k] = v
+	}
+	return plain, sensitive
+}
+
+// validateSensitiveEnv checks that none of the sensitive environment variables
+// is also set as plain environment variable
+func validateSensitiveEnv(env []string, sensitiveEnv map[string]interface{}) error {
+	for k := range mapStringSliceToMap(env) {
+		if _, ok := sensitiveEnv[k]; ok {
+			return fmt.Errorf("Environment variable %s must not be set in both env and sensitive_env", k)
+		}
+	}
+	return nil
+}
+
 // mapStringStringToMapStringInterface maps a string string map to a string interface map
 func mapStringStringToMapStringInterface(in map[string]string) map[string]interface{} {
 	if in == nil || len(in) == 0 {
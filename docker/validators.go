@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/containerd/containerd/platforms"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -36,6 +38,62 @@ func validateIntegerGeqThan(threshold int) schema.SchemaValidateFunc {
 	}
 }
 
+func validateSizeGeqThan(threshold int64) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value, err := parseSize(v.(string), sizeUnitBytes)
+		if err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q must be a number or a size like '512m' or '2GiB': %s", k, err))
+			return
+		}
+		if value < threshold {
+			errors = append(errors, fmt.Errorf(
+				"%q cannot be lower than %d", k, threshold))
+		}
+		return
+	}
+}
+
+func validateImagePlatform() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if _, err := platforms.Parse(v.(string)); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q must be a platform like 'linux/arm64': %s", k, err))
+		}
+		return
+	}
+}
+
+func validateRegexp() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if _, err := regexp.Compile(v.(string)); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q must be a valid regular expression: %s", k, err))
+		}
+		return
+	}
+}
+
+func validateVersionConstraint() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if _, err := version.NewConstraint(v.(string)); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q must be a version constraint like '~> 2.0': %s", k, err))
+		}
+		return
+	}
+}
+
+func validateSignaturePublicKey() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if _, err := parseSignaturePublicKey(v.(string)); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q must be a PEM encoded public key: %s", k, err))
+		}
+		return
+	}
+}
+
 func validateFloatRatio() schema.SchemaValidateFunc {
 	return func(v interface{}, k string) (ws []string, errors []error) {
 		value := v.(float64)
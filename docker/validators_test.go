@@ -34,6 +34,66 @@ func TestValidateIntegerGeqThan0(t *testing.T) {
 	}
 }
 
+func TestValidateSizeGeqThan(t *testing.T) {
+	validSizes := []string{"0", "512", "512m", "512M", "2GiB", "1.5g", "-1"}
+	for _, v := range validSizes {
+		if _, errors := validateSizeGeqThan(-1)(v, "name"); len(errors) != 0 {
+			t.Fatalf("%q should be a valid size: %q", v, errors)
+		}
+	}
+
+	invalidSizes := []string{"-2", "foo", "512x", "m"}
+	for _, v := range invalidSizes {
+		if _, errors := validateSizeGeqThan(-1)(v, "name"); len(errors) == 0 {
+			t.Fatalf("%q should be an invalid size", v)
+		}
+	}
+}
+
+func TestValidateImagePlatform(t *testing.T) {
+	validPlatforms := []string{"linux/amd64", "linux/arm64", "linux/arm/v7", "windows/amd64"}
+	for _, v := range validPlatforms {
+		if _, errors := validateImagePlatform()(v, "name"); len(errors) != 0 {
+			t.Fatalf("%q should be a valid platform: %q", v, errors)
+		}
+	}
+
+	invalidPlatforms := []string{"", "linux/*", "linux/arm64/v8/foo", "Linux AMD64"}
+	for _, v := range invalidPlatforms {
+		if _, errors := validateImagePlatform()(v, "name"); len(errors) == 0 {
+			t.Fatalf("%q should be an invalid platform", v)
+		}
+	}
+}
+
+func TestValidateRegexp(t *testing.T) {
+	v := `^\d+\.\d+$`
+	if _, errors := validateRegexp()(v, "name"); len(errors) != 0 {
+		t.Fatalf("%q should be a valid regular expression: %q", v, errors)
+	}
+
+	v = `^(\d+$`
+	if _, errors := validateRegexp()(v, "name"); len(errors) == 0 {
+		t.Fatalf("%q should be an invalid regular expression", v)
+	}
+}
+
+func TestValidateVersionConstraint(t *testing.T) {
+	validConstraints := []string{"~> 2.0", ">= 1.10, < 3", "= 1.2.3", "2.1"}
+	for _, v := range validConstraints {
+		if _, errors := validateVersionConstraint()(v, "name"); len(errors) != 0 {
+			t.Fatalf("%q should be a valid version constraint: %q", v, errors)
+		}
+	}
+
+	invalidConstraints := []string{"", "latest", "~> 2.x", ">> 1"}
+	for _, v := range invalidConstraints {
+		if _, errors := validateVersionConstraint()(v, "name"); len(errors) == 0 {
+			t.Fatalf("%q should be an invalid version constraint", v)
+		}
+	}
+}
+
 func TestValidateFloatRatio(t *testing.T) {
 	v := 0.9
 	if _, error := validateFloatRatio()(v, "name"); error != nil {
@@ -3,19 +3,27 @@ module github.com/terraform-providers/terraform-provider-docker
 require (
 	github.com/Azure/go-ansiterm v0.0.0-20170929234023-d6e3b3328b78 // indirect
 	github.com/Microsoft/hcsshim v0.8.9 // indirect
+	github.com/containerd/containerd v1.3.2
 	github.com/containerd/continuity v0.0.0-20200710164510-efbc4488d8fe // indirect
 	github.com/docker/cli v0.0.0-20200303215952-eb310fca4956 // v19.03.8
-	github.com/docker/distribution v0.0.0-20180522175653-f0cc92778478 // indirect
+	github.com/docker/distribution v2.7.1-0.20190205005809-0d3efadf0154+incompatible
 	github.com/docker/docker v0.7.3-0.20190525203055-f25e0c6f3093
-	github.com/docker/docker-credential-helpers v0.6.3
+	github.com/docker/docker-credential-helpers v0.6.3 // indirect
 	github.com/docker/go-connections v0.4.0
 	github.com/docker/go-units v0.4.0
 	github.com/gorilla/mux v1.7.2 // indirect
+	github.com/hashicorp/go-version v1.2.0
 	github.com/hashicorp/terraform-plugin-sdk v1.0.0
 	github.com/mitchellh/go-homedir v1.1.0
-	github.com/morikuni/aec v0.0.0-20170113033406-39771216ff4c // indirect
-	github.com/opencontainers/image-spec v0.0.0-20171125024018-577479e4dc27 // indirect
-	github.com/opencontainers/runc v0.1.1 // indirect
+	github.com/moby/buildkit v0.6.4
 )
 
 go 1.15
+
+// buildkit requires docker and containerd at pseudo versions which cannot be resolved,
+// so docker stays at the commit the provider used before, which has the DialHijack
+// API of BuildKit sessions, and containerd uses the first release after the excluded version
+exclude (
+	github.com/containerd/containerd v1.3.0-0.20190507210959-7c1e88399ec0
+	github.com/docker/docker v1.14.0-0.20190319215453-e7b5f7dbe98c
+)